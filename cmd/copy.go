@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/jacobweinstock/ovaimporter/pkg/vsphere"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	copyFromURL        string
+	copyFromUser       string
+	copyFromPassword   string
+	copyFromDatacenter string
+	copyFromVM         string
+	copyToURL          string
+	copyToUser         string
+	copyToPassword     string
+	copyToDatacenter   string
+	copyToFolder       string
+
+	copyCmd = &cobra.Command{
+		Use:   "copy",
+		Short: "copy a template or VM between vCenters, streaming the disks without staging them on disk",
+		Run: func(cmd *cobra.Command, args []string) {
+			var c copyResponse
+			err := c.run()
+			response(c, err)
+		},
+	}
+)
+
+func init() {
+	copyCmd.Flags().StringVar(&copyFromURL, "from-url", "", "url of the vCenter to copy from (default is --url)")
+	copyCmd.Flags().StringVar(&copyFromUser, "from-user", "", "username of --from-url (default is --user)")
+	copyCmd.Flags().StringVar(&copyFromPassword, "from-password", "", "password of --from-url (default is --password)")
+	copyCmd.Flags().StringVar(&copyFromDatacenter, "from-datacenter", "", "datacenter of --from-url (default is --datacenter)")
+	copyCmd.Flags().StringVar(&copyFromVM, "from-vm", "", "name or inventory path of the template or VM to copy")
+	copyCmd.Flags().StringVar(&copyToURL, "to-url", "", "url of the vCenter to copy to")
+	copyCmd.Flags().StringVar(&copyToUser, "to-user", "", "username of --to-url (default is --user)")
+	copyCmd.Flags().StringVar(&copyToPassword, "to-password", "", "password of --to-url (default is --password)")
+	copyCmd.Flags().StringVar(&copyToDatacenter, "to-datacenter", "", "datacenter of --to-url (default is --datacenter)")
+	copyCmd.Flags().StringVar(&copyToFolder, "to-folder", "", "folder of --to-url to copy to")
+	_ = copyCmd.MarkFlagRequired("from-vm")
+	_ = copyCmd.MarkFlagRequired("to-url")
+	rootCmd.AddCommand(copyCmd)
+}
+
+func (c *copyResponse) run() error {
+	tout := time.Duration(timeout) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), tout)
+	defer cancel()
+
+	source, err := connectTo(ctx, orDefault(copyFromURL, url), orDefault(copyFromUser, user), orDefault(copyFromPassword, password), orDefault(copyFromDatacenter, datacenter))
+	if err != nil {
+		return errors.WithMessage(err, "unable to connect to the source vCenter")
+	}
+	target, err := connectTo(ctx, copyToURL, orDefault(copyToUser, user), orDefault(copyToPassword, password), orDefault(copyToDatacenter, datacenter))
+	if err != nil {
+		return errors.WithMessage(err, "unable to connect to the target vCenter")
+	}
+	if err := setComputeTargets(target); err != nil {
+		return err
+	}
+	if target.Folder, err = target.GetFolderOrDefault(copyToFolder); err != nil {
+		return err
+	}
+	if target.Datastore, err = target.GetDatastoreOrDefault(datastore); err != nil {
+		return err
+	}
+	if target.Network, err = target.GetNetworkOrDefault(network); err != nil {
+		return err
+	}
+	target.Options.DiskProvisioning = diskProvisioning
+
+	vm, err := source.GetVM(copyFromVM)
+	if err != nil {
+		return err
+	}
+	// the progress goes to stderr, stdout and the response file only get the response
+	progress := log.New()
+	progress.SetFormatter(&log.JSONFormatter{})
+	progress.SetOutput(os.Stderr)
+	result, err := source.Copy(vm, target, vsphere.CopyOptions{
+		Name: name,
+		Progress: func(p vsphere.CopyProgress) {
+			progress.WithFields(log.Fields{"file": p.File, "transferred": p.Transferred, "total": p.Total}).Info("copying")
+		},
+	})
+	c.CopyResult = result
+	if err != nil {
+		return err
+	}
+	c.Success = true
+	return nil
+}
+
+// orDefault returns value, or def when value isn't set
+func orDefault(value string, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportVM     string
+	exportOutput string
+
+	exportCmd = &cobra.Command{
+		Use:   "export",
+		Short: "export a template or VM to an OVA file, or to stdout with --output -",
+		Run: func(cmd *cobra.Command, args []string) {
+			var e exportResponse
+			err := e.run()
+			response(e, err)
+		},
+	}
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&exportVM, "vm", "", "name or inventory path of the template or VM to export")
+	exportCmd.Flags().StringVar(&exportOutput, "output", "", "OVA file to write, - writes it to stdout")
+	_ = exportCmd.MarkFlagRequired("vm")
+	_ = exportCmd.MarkFlagRequired("output")
+	rootCmd.AddCommand(exportCmd)
+}
+
+func (e *exportResponse) run() error {
+	tout := time.Duration(timeout) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), tout)
+	defer cancel()
+
+	e.Output = exportOutput
+	if exportOutput == "-" {
+		// stdout is the OVA, the response only goes to stderr and the response file
+		log.SetOutput(io.MultiWriter(os.Stderr, responseFile))
+		err := e.export(ctx, os.Stdout)
+		e.Success = err == nil
+		return err
+	}
+	f, err := os.Create(exportOutput)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create %v", exportOutput)
+	}
+	err = e.export(ctx, f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = errors.Wrapf(closeErr, "unable to write %v", exportOutput)
+	}
+	if err != nil {
+		// don't leave a partial OVA behind
+		_ = os.Remove(exportOutput)
+		return err
+	}
+	e.Success = true
+	return nil
+}
+
+func (e *exportResponse) export(ctx context.Context, w io.Writer) error {
+	client, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	vm, err := client.GetVM(exportVM)
+	if err != nil {
+		return err
+	}
+	e.ExportResult, err = client.Export(vm, w)
+	return err
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/jacobweinstock/ovaimporter/pkg/vsphere"
+	"github.com/spf13/cobra"
+)
+
+var (
+	gcKeep       int
+	gcProtectTag string
+	gcDryRun     bool
+
+	gcCmd = &cobra.Command{
+		Use:   "gc",
+		Short: "remove the oldest templates of a --family, keeping the newest ones",
+		Run: func(cmd *cobra.Command, args []string) {
+			var gc gcResponse
+			err := gc.run()
+			response(gc, err)
+		},
+	}
+)
+
+func init() {
+	gcCmd.Flags().IntVar(&gcKeep, "keep", 3, "number of newest templates of the family to keep")
+	gcCmd.Flags().StringVar(&gcProtectTag, "protect-tag", "", "templates with this tag (category/tag) are never removed")
+	gcCmd.Flags().BoolVar(&gcDryRun, "dry-run", false, "report what would be removed without removing it")
+	rootCmd.AddCommand(gcCmd)
+}
+
+func (g *gcResponse) run() error {
+	tout := time.Duration(timeout) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), tout)
+	defer cancel()
+
+	client, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := client.GarbageCollect(vsphere.GCOptions{
+		Family:     family,
+		Keep:       gcKeep,
+		ProtectTag: gcProtectTag,
+		DryRun:     gcDryRun,
+	})
+	g.GCResult = result
+	if err != nil {
+		return err
+	}
+	g.Success = true
+	return nil
+}
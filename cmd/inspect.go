@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/jacobweinstock/ovaimporter/pkg/vsphere"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect",
+	Short: "print the OVF details of an ova, including any EULA text",
+	Run: func(cmd *cobra.Command, args []string) {
+		var inspectOva inspectResponse
+		err := inspectOva.run()
+		response(inspectOva, err)
+	},
+}
+
+func init() {
+	inspectCmd.Flags().StringVar(&ova, "ova", "", "local file or remote URL of an OVA to inspect")
+	_ = inspectCmd.MarkFlagRequired("ova")
+	rootCmd.AddCommand(inspectCmd)
+}
+
+func (i *inspectResponse) run() error {
+	info, err := vsphere.Inspect(ova)
+	if err != nil {
+		return err
+	}
+	i.OVAInfo = info
+	i.Success = true
+	return nil
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/jacobweinstock/ovaimporter/pkg/vsphere"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promoteFrom         string
+	promoteTo           string
+	promoteClone        bool
+	promoteToVCenter    string
+	promoteToUser       string
+	promoteToPassword   string
+	promoteToDatacenter string
+
+	promoteCmd = &cobra.Command{
+		Use:   "promote",
+		Short: "move or clone the template --name from a folder or tag to another one, recording the promotion in its metadata",
+		Run: func(cmd *cobra.Command, args []string) {
+			var p promoteResponse
+			err := p.run()
+			response(p, err)
+		},
+	}
+)
+
+func init() {
+	promoteCmd.Flags().StringVar(&promoteFrom, "from", "", "folder, or tag:<category/tag>, the template is promoted from")
+	promoteCmd.Flags().StringVar(&promoteTo, "to", "", "folder, or tag:<category/tag>, the template is promoted to")
+	promoteCmd.Flags().BoolVar(&promoteClone, "clone", false, "promote a clone of the template and keep the template in --from")
+	promoteCmd.Flags().StringVar(&promoteToVCenter, "to-vcenter", "", "url of another vCenter the template is cloned to")
+	promoteCmd.Flags().StringVar(&promoteToUser, "to-user", "", "username of --to-vcenter (default is --user)")
+	promoteCmd.Flags().StringVar(&promoteToPassword, "to-password", "", "password of --to-vcenter (default is --password)")
+	promoteCmd.Flags().StringVar(&promoteToDatacenter, "to-datacenter", "", "datacenter of --to-vcenter (default is --datacenter)")
+	_ = promoteCmd.MarkFlagRequired("from")
+	_ = promoteCmd.MarkFlagRequired("to")
+	rootCmd.AddCommand(promoteCmd)
+}
+
+func (p *promoteResponse) run() error {
+	tout := time.Duration(timeout) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), tout)
+	defer cancel()
+
+	if name == "" {
+		return errors.New("required flag \"name\" not set")
+	}
+	client, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	opts := vsphere.PromoteOptions{Name: name, From: promoteFrom, To: promoteTo, Clone: promoteClone}
+	target := client
+	if promoteToVCenter != "" {
+		if opts.Target, err = connectTarget(ctx); err != nil {
+			return err
+		}
+		target = opts.Target
+	}
+	// clones are placed with the compute and datastore flags, on the vCenter they're cloned to
+	if opts.Clone || opts.Target != nil {
+		if err := setComputeTargets(target); err != nil {
+			return err
+		}
+		if target.Datastore, err = target.GetDatastoreOrDefault(datastore); err != nil {
+			return err
+		}
+	}
+	result, err := client.Promote(opts)
+	p.PromoteResult = result
+	if err != nil {
+		return err
+	}
+	p.Success = true
+	return nil
+}
+
+// connectTarget logs in to the vCenter of --to-vcenter, the credentials and datacenter default to the source's
+func connectTarget(ctx context.Context) (*vsphere.Session, error) {
+	target, err := connectTo(ctx, promoteToVCenter, orDefault(promoteToUser, user), orDefault(promoteToPassword, password), orDefault(promoteToDatacenter, datacenter))
+	return target, errors.WithMessagef(err, "unable to connect to %v", promoteToVCenter)
+}
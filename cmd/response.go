@@ -1,26 +1,175 @@
 package cmd
 
 import (
+	"github.com/jacobweinstock/ovaimporter/pkg/vsphere"
 	"github.com/sirupsen/logrus"
 )
 
+type responder interface {
+	ToLogrusFields() logrus.Fields
+}
+
 type baseResponse struct {
 	Success  bool   `json:"success"`
 	ErrorMsg string `json:"errorMsg"`
 }
 
 type importerResponse struct {
-	Name          string `json:"name"`
-	AlreadyExists bool   `json:"alreadyExists"`
-	baseResponse  `json:",inline"`
+	Name               string                      `json:"name"`
+	AlreadyExists      bool                        `json:"alreadyExists"`
+	Kind               string                      `json:"kind"`
+	Drift              string                      `json:"drift,omitempty"`
+	Family             string                      `json:"family,omitempty"`
+	Version            string                      `json:"version,omitempty"`
+	Source             vsphere.SourceInfo          `json:"source"`
+	Preflight          vsphere.PreflightResult     `json:"preflight"`
+	EULAHash           string                      `json:"eulaSha256,omitempty"`
+	StoragePolicyID    string                      `json:"storagePolicyId,omitempty"`
+	KeyProvider        string                      `json:"keyProvider,omitempty"`
+	NICs               []vsphere.NIC               `json:"nics"`
+	Snapshot           string                      `json:"snapshot,omitempty"`
+	SmokeTest          *vsphere.SmokeTestResult    `json:"smokeTest,omitempty"`
+	Replaced           *vsphere.ReplaceResult      `json:"replaced,omitempty"`
+	Replicas           []vsphere.Replica           `json:"replicas,omitempty"`
+	LibraryItem        *vsphere.LibraryItem        `json:"libraryItem,omitempty"`
+	DatastoreSelection *vsphere.DatastoreSelection `json:"datastoreSelection,omitempty"`
+	StoragePlacement   *vsphere.StoragePlacement   `json:"storagePlacement,omitempty"`
+	HostPlacement      *vsphere.HostPlacement      `json:"hostPlacement,omitempty"`
+	baseResponse       `json:",inline"`
 }
 
 // ToLogrusFields is a helper for the logrus library
 func (i importerResponse) ToLogrusFields() logrus.Fields {
 	return logrus.Fields{
-		"success":       i.Success,
-		"errorMsg":      i.ErrorMsg,
-		"name":          i.Name,
-		"alreadyExists": i.AlreadyExists,
+		"success":            i.Success,
+		"errorMsg":           i.ErrorMsg,
+		"name":               i.Name,
+		"alreadyExists":      i.AlreadyExists,
+		"kind":               i.Kind,
+		"drift":              i.Drift,
+		"family":             i.Family,
+		"version":            i.Version,
+		"source":             i.Source,
+		"preflight":          i.Preflight,
+		"eulaSha256":         i.EULAHash,
+		"storagePolicyId":    i.StoragePolicyID,
+		"keyProvider":        i.KeyProvider,
+		"nics":               i.NICs,
+		"snapshot":           i.Snapshot,
+		"smokeTest":          i.SmokeTest,
+		"replaced":           i.Replaced,
+		"replicas":           i.Replicas,
+		"libraryItem":        i.LibraryItem,
+		"datastoreSelection": i.DatastoreSelection,
+		"storagePlacement":   i.StoragePlacement,
+		"hostPlacement":      i.HostPlacement,
+	}
+}
+
+type inspectResponse struct {
+	vsphere.OVAInfo `json:",inline"`
+	baseResponse    `json:",inline"`
+}
+
+// ToLogrusFields is a helper for the logrus library
+func (i inspectResponse) ToLogrusFields() logrus.Fields {
+	return logrus.Fields{
+		"success":          i.Success,
+		"errorMsg":         i.ErrorMsg,
+		"name":             i.Name,
+		"product":          i.Product,
+		"vendor":           i.Vendor,
+		"version":          i.Version,
+		"hardwareVersions": i.HardwareVersions,
+		"guestId":          i.GuestID,
+		"eula":             i.EULA,
+		"eulaSha256":       i.EULAHash,
+	}
+}
+
+type gcResponse struct {
+	vsphere.GCResult `json:",inline"`
+	baseResponse     `json:",inline"`
+}
+
+// ToLogrusFields is a helper for the logrus library
+func (g gcResponse) ToLogrusFields() logrus.Fields {
+	return logrus.Fields{
+		"success":  g.Success,
+		"errorMsg": g.ErrorMsg,
+		"family":   g.Family,
+		"keep":     g.Keep,
+		"dryRun":   g.DryRun,
+		"kept":     g.Kept,
+		"removed":  g.Removed,
+		"skipped":  g.Skipped,
+	}
+}
+
+type templateResponse struct {
+	Templates    []vsphere.TemplateInfo `json:"templates"`
+	baseResponse `json:",inline"`
+}
+
+// ToLogrusFields is a helper for the logrus library
+func (t templateResponse) ToLogrusFields() logrus.Fields {
+	return logrus.Fields{
+		"success":   t.Success,
+		"errorMsg":  t.ErrorMsg,
+		"templates": t.Templates,
+	}
+}
+
+type promoteResponse struct {
+	vsphere.PromoteResult `json:",inline"`
+	baseResponse          `json:",inline"`
+}
+
+// ToLogrusFields is a helper for the logrus library
+func (p promoteResponse) ToLogrusFields() logrus.Fields {
+	return logrus.Fields{
+		"success":  p.Success,
+		"errorMsg": p.ErrorMsg,
+		"name":     p.Name,
+		"from":     p.From,
+		"to":       p.To,
+		"action":   p.Action,
+		"template": p.Template,
+		"history":  p.History,
+	}
+}
+
+type exportResponse struct {
+	vsphere.ExportResult `json:",inline"`
+	Output               string `json:"output"`
+	baseResponse         `json:",inline"`
+}
+
+// ToLogrusFields is a helper for the logrus library
+func (e exportResponse) ToLogrusFields() logrus.Fields {
+	return logrus.Fields{
+		"success":  e.Success,
+		"errorMsg": e.ErrorMsg,
+		"name":     e.Name,
+		"output":   e.Output,
+		"files":    e.Files,
+	}
+}
+
+type copyResponse struct {
+	vsphere.CopyResult `json:",inline"`
+	baseResponse       `json:",inline"`
+}
+
+// ToLogrusFields is a helper for the logrus library
+func (c copyResponse) ToLogrusFields() logrus.Fields {
+	return logrus.Fields{
+		"success":       c.Success,
+		"errorMsg":      c.ErrorMsg,
+		"name":          c.Name,
+		"id":            c.ID,
+		"kind":          c.Kind,
+		"alreadyExists": c.AlreadyExists,
+		"files":         c.Files,
 	}
 }
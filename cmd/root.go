@@ -5,12 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jacobweinstock/ovaimporter/pkg/vsphere"
 
+	"github.com/pkg/errors"
 	"github.com/spf13/pflag"
 
 	homedir "github.com/mitchellh/go-homedir"
@@ -28,13 +32,59 @@ var (
 	password                      string
 	datacenter                    string
 	ova                           string
+	name                          string
+	nameTemplate                  string
 	folder                        string
 	network                       string
 	datastore                     string
+	datastoreSelect               string
+	datastoreCluster              string
+	cluster                       string
+	host                          string
+	resourcePool                  string
+	snapshot                      string
+	replace                       bool
+	replaceOld                    string
+	onDrift                       string
+	family                        string
+	familyVersion                 string
+	replicateTo                   string
+	replicaConcurrency            int
+	contentLibrary                string
+	libraryItem                   string
+	storagePolicy                 string
+	encrypt                       bool
+	keyProvider                   string
+	reconfigFile                  string
+	numCPUs                       int32
+	coresPerSocket                int32
+	memoryMB                      int64
+	diskSizeGB                    map[string]int64
+	enableDiskUUID                bool
+	extraConfig                   map[string]string
+	firmware                      string
+	secureBoot                    bool
+	guestInfoUserData             string
+	guestInfoMetaData             string
+	guestInfo                     map[string]string
+	mode                          string
+	smokeTest                     bool
+	smokeTestTimeout              time.Duration
+	smokeTestPort                 int
+	smokeTestFailure              string
+	nicPolicy                     string
+	nicAdapterType                string
+	nicNetwork                    string
 	timeout                       int
+	skipPreflight                 bool
+	acceptEULA                    bool
+	diskProvisioning              string
+	minFreePercent                float64
 	responseFileDirectory         string
 	responseFileName              = "response.json"
 	responseFileDirectoryFallback = "./"
+	// responseFile is where the response is written next to stdout
+	responseFile io.Writer
 
 	rootCmd = &cobra.Command{
 		Use:     appName,
@@ -43,8 +93,8 @@ var (
 		Version: version,
 		Run: func(cmd *cobra.Command, args []string) {
 			var importOva importerResponse
-			err := importOva.run()
-			importOva.response(err)
+			err := importOva.run(cmd.Flags())
+			response(importOva, err)
 		},
 	}
 )
@@ -79,64 +129,326 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&url, "url", "", "vCenter url")
 	rootCmd.PersistentFlags().StringVar(&user, "user", "", "vCenter username")
 	rootCmd.PersistentFlags().StringVar(&password, "password", "", "vCenter password")
-	rootCmd.PersistentFlags().IntVar(&timeout, "timeout", 5, "timeout")
-	rootCmd.PersistentFlags().StringVar(&ova, "ova", "", "local file or remote URL of an OVA to import")
+	rootCmd.PersistentFlags().IntVar(&timeout, "timeout", 5, "minutes the command may take, including the upload and the smoke test")
 	rootCmd.PersistentFlags().StringVar(&folder, "folder", "", "folder into which to upload the OVA (example vm/my/folder)")
+	rootCmd.PersistentFlags().StringVar(&name, "name", "", "name of the template (default is the file name of the OVA)")
+	rootCmd.PersistentFlags().StringVar(&nameTemplate, "name-template", "", "name of the template with placeholders: {name}, {product}, {vendor}, {version}, {digest}, {date}")
 	rootCmd.PersistentFlags().StringVar(&network, "network", "", "network to attach to the template")
 	rootCmd.PersistentFlags().StringVar(&datastore, "datastore", "", "vCenter datastore to which to upload the OVA")
+	rootCmd.PersistentFlags().StringVar(&datastoreSelect, "datastore-select", "", "pick the datastore by policy instead of name (most-free, least-used, regex:<pattern>, tag:<category/tag>)")
+	rootCmd.PersistentFlags().StringVar(&datastoreCluster, "datastore-cluster", "", "datastore cluster (storage pod) to which to upload the OVA, placed by Storage DRS")
 	rootCmd.PersistentFlags().StringVar(&datacenter, "datacenter", "", "vCenter datacenter name")
-	_ = rootCmd.MarkPersistentFlagRequired("ova")
-	_ = rootCmd.MarkPersistentFlagRequired("url")
-	_ = rootCmd.MarkPersistentFlagRequired("user")
-	_ = rootCmd.MarkPersistentFlagRequired("password")
+	rootCmd.PersistentFlags().StringVar(&cluster, "cluster", "", "cluster to import to, the host is picked by DRS unless --host is set")
+	rootCmd.PersistentFlags().StringVar(&host, "host", "", "host to import to")
+	rootCmd.PersistentFlags().StringVar(&resourcePool, "resource-pool", "", "resource pool to import to (default is the root pool of the host or cluster)")
+	rootCmd.PersistentFlags().BoolVar(&skipPreflight, "skip-preflight", false, "skip the hardware and guest OS compatibility, datastore capacity and host access checks")
+	rootCmd.PersistentFlags().BoolVar(&acceptEULA, "accept-eula", false, "accept the EULA of the OVA, required when the OVA has one")
+	rootCmd.PersistentFlags().StringVar(&diskProvisioning, "disk-provisioning", "thin", "disk provisioning of the template (thin, thick, eagerZeroedThick)")
+	rootCmd.PersistentFlags().StringVar(&storagePolicy, "storage-policy", "", "VM storage policy applied to the template and its disks")
+	rootCmd.PersistentFlags().BoolVar(&encrypt, "encrypt", false, "encrypt the template with a key of --key-provider, or with the encryption of --storage-policy")
+	rootCmd.PersistentFlags().StringVar(&keyProvider, "key-provider", "", "key provider (KMS cluster) used by --encrypt (default is the vCenter's default key provider)")
+	rootCmd.PersistentFlags().StringVar(&reconfigFile, "reconfig-file", "", "YAML file with the reconfiguration applied after the import, flags override it")
+	rootCmd.PersistentFlags().Int32Var(&numCPUs, "num-cpus", 0, "number of CPUs of the template")
+	rootCmd.PersistentFlags().Int32Var(&coresPerSocket, "cores-per-socket", 0, "cores per CPU socket of the template")
+	rootCmd.PersistentFlags().Int64Var(&memoryMB, "memory-mb", 0, "memory of the template in MB")
+	rootCmd.PersistentFlags().StringToInt64Var(&diskSizeGB, "disk-size-gb", nil, "grow disks of the template, by label (\"Hard disk 1=40\")")
+	rootCmd.PersistentFlags().BoolVar(&enableDiskUUID, "enable-disk-uuid", false, "set disk.EnableUUID=TRUE, needed by the vSphere CSI driver")
+	rootCmd.PersistentFlags().StringToStringVar(&extraConfig, "extra-config", nil, "extraConfig keys to set on the template (key=value)")
+	rootCmd.PersistentFlags().StringVar(&firmware, "firmware", "", "firmware of the template (bios, efi)")
+	rootCmd.PersistentFlags().BoolVar(&secureBoot, "secure-boot", false, "enable or disable EFI secure boot of the template")
+	rootCmd.PersistentFlags().StringVar(&guestInfoUserData, "guestinfo-userdata", "", "file written to guestinfo.userdata of the template, for cloud-init")
+	rootCmd.PersistentFlags().StringVar(&guestInfoMetaData, "guestinfo-metadata", "", "file written to guestinfo.metadata of the template, for cloud-init")
+	rootCmd.PersistentFlags().StringToStringVar(&guestInfo, "guestinfo", nil, "guestinfo values of the template (key=value, written to guestinfo.<key>)")
+	rootCmd.PersistentFlags().StringVar(&nicPolicy, "nic-policy", vsphere.NICPolicyKeep, "what to do with the NICs of the template (keep, remove, replace)")
+	rootCmd.PersistentFlags().StringVar(&nicAdapterType, "nic-adapter-type", "vmxnet3", "adapter type of the NICs with --nic-policy replace")
+	rootCmd.PersistentFlags().StringVar(&nicNetwork, "nic-network", "", "network the NICs are connected to with --nic-policy replace (default is --network)")
+	rootCmd.PersistentFlags().StringVar(&mode, "mode", "", "what the OVA is imported as (template, vm, vm-poweron), default is template or vm on a standalone ESXi host")
+	rootCmd.PersistentFlags().BoolVar(&smokeTest, "smoke-test", false, "boot a throwaway clone of the template and wait for VMware Tools and an IP")
+	rootCmd.PersistentFlags().DurationVar(&smokeTestTimeout, "smoke-test-timeout", 0, "how long the smoke test waits for VMware Tools, an IP and --smoke-test-port (default what is left of --timeout, less 30s for the cleanup)")
+	rootCmd.PersistentFlags().IntVar(&smokeTestPort, "smoke-test-port", 0, "TCP port the smoke test probes on the IP of the clone")
+	rootCmd.PersistentFlags().StringVar(&smokeTestFailure, "smoke-test-failure", vsphere.SmokeTestFailureDelete, "what to do with a template that fails the smoke test (delete, quarantine)")
+	rootCmd.PersistentFlags().StringVar(&snapshot, "snapshot", "", "name of a snapshot taken before the VM is marked as a template, for linked clones")
+	rootCmd.PersistentFlags().BoolVar(&replace, "replace", false, "replace an existing template with a new import of the OVA")
+	rootCmd.PersistentFlags().StringVar(&replaceOld, "replace-old", vsphere.ReplaceOldDelete, "what to do with the template replaced by --replace (delete, keep)")
+	rootCmd.PersistentFlags().StringVar(&onDrift, "on-drift", vsphere.OnDriftSkip, "what to do when an existing template drifted from the OVA (skip, fail, replace)")
+	rootCmd.PersistentFlags().StringVar(&family, "family", "", "family the template is tagged with, for retention with the gc command")
+	rootCmd.PersistentFlags().StringVar(&familyVersion, "family-version", "", "version the template is tagged with in its family (default is the OVF product version)")
+	rootCmd.PersistentFlags().StringVar(&replicateTo, "replicate-to", "", "clone the template to datastores as <name>-<datastore> (comma separated names, regex:<pattern>, all-accessible)")
+	rootCmd.PersistentFlags().IntVar(&replicaConcurrency, "replica-concurrency", 4, "number of replicas cloned at the same time")
+	rootCmd.PersistentFlags().StringVar(&contentLibrary, "content-library", "", "name of a local content library the OVA is also uploaded to")
+	rootCmd.PersistentFlags().StringVar(&libraryItem, "library-item", "", "name of the content library item (defaults to the template name)")
+	rootCmd.PersistentFlags().Float64Var(&minFreePercent, "min-free-percent", 0, "percent of the datastore capacity that must remain free after the import")
+	rootCmd.Flags().StringVar(&ova, "ova", "", "local file or remote URL of an OVA to import")
+	_ = rootCmd.MarkFlagRequired("ova")
 	info, _ := json.Marshal(appInfo)
 	rootCmd.SetVersionTemplate(string(info))
 }
 
-func (i *importerResponse) run() error {
+func (i *importerResponse) run(flags *pflag.FlagSet) error {
 	var err error
 	tout := time.Duration(timeout) * time.Minute
+	if smokeTestTimeout > tout {
+		return errors.New(fmt.Sprintf("--smoke-test-timeout %v is longer than --timeout %v", smokeTestTimeout, tout))
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), tout)
 	defer cancel()
 
-	client, err := vsphere.NewClient(ctx, url, user, password)
+	client, err := connect(ctx)
 	if err != nil {
 		return err
 	}
-	client.Datacenter, err = client.GetDatacenterOrDefault(datacenter)
+	client.Options.Name = name
+	client.Options.NameTemplate = nameTemplate
+	client.Options.SkipPreflight = skipPreflight
+	client.Options.AcceptEULA = acceptEULA
+	client.Options.DiskProvisioning = diskProvisioning
+	client.Options.MinFreePercent = minFreePercent
+	client.Options.Snapshot = snapshot
+	client.Options.Replace = replace
+	client.Options.ReplaceOld = replaceOld
+	client.Options.OnDrift = onDrift
+	client.Options.Family = family
+	client.Options.Version = familyVersion
+	client.Options.ReplicateTo = replicateTo
+	client.Options.ReplicaConcurrency = replicaConcurrency
+	client.Options.ContentLibrary = contentLibrary
+	client.Options.LibraryItem = libraryItem
+	client.Options.Mode = mode
+	client.Options.SmokeTest = smokeTest
+	client.Options.SmokeTestTimeout = smokeTestTimeout
+	client.Options.SmokeTestPort = smokeTestPort
+	client.Options.SmokeTestFailure = smokeTestFailure
+	client.Options.StoragePolicy = storagePolicy
+	client.Options.Encrypt = encrypt
+	client.Options.KeyProvider = keyProvider
+	client.Options.Reconfig, err = reconfig(flags)
 	if err != nil {
 		return err
 	}
-	client.Network, err = client.GetNetworkOrDefault(network)
+	client.Options.GuestInfo, err = guestInfoValues()
 	if err != nil {
 		return err
 	}
-	client.Datastore, err = client.GetDatastoreOrDefault(datastore)
+	if client.IsESXi() && countSet(cluster, datastoreCluster) > 0 {
+		return errors.New("--cluster and --datastore-cluster need a vCenter, not a standalone ESXi host")
+	}
+	client.Network, err = client.GetNetworkOrDefault(network)
 	if err != nil {
 		return err
 	}
-	// resource pool is need for the upload but doesnt really matter so we use the default
-	client.ResourcePool, err = client.GetResourcePoolOrDefault("")
-	if err != nil {
+	client.Options.NICPolicy = nicPolicy
+	client.Options.NICAdapterType = nicAdapterType
+	if nicNetwork != "" {
+		client.Options.NICNetwork, err = client.GetNetworkOrDefault(nicNetwork)
+		if err != nil {
+			return err
+		}
+	}
+	if err := setComputeTargets(client); err != nil {
 		return err
 	}
 	client.Folder, err = client.GetFolderOrDefault(folder)
 	if err != nil {
 		return err
 	}
+	switch {
+	case countSet(datastore, datastoreSelect, datastoreCluster) > 1:
+		return errors.New("--datastore, --datastore-select and --datastore-cluster are mutually exclusive")
+	case datastoreCluster != "":
+		pod, err := client.GetDatastoreClusterOrDefault(datastoreCluster)
+		if err != nil {
+			return err
+		}
+		var placement vsphere.StoragePlacement
+		client.Datastore, placement, err = client.PlaceOnDatastoreCluster(pod, ova)
+		if err != nil {
+			return err
+		}
+		i.StoragePlacement = &placement
+	case datastoreSelect != "":
+		var selection vsphere.DatastoreSelection
+		client.Datastore, selection, err = client.SelectDatastore(datastoreSelect)
+		if err != nil {
+			return err
+		}
+		i.DatastoreSelection = &selection
+	default:
+		client.Datastore, err = client.GetDatastoreOrDefault(datastore)
+		if err != nil {
+			return err
+		}
+	}
+	if client.Cluster != nil && client.Host == nil {
+		var placement vsphere.HostPlacement
+		client.Host, placement, err = client.PlaceOnCluster(client.Cluster, ova)
+		if err != nil {
+			return err
+		}
+		i.HostPlacement = &placement
+	}
 
 	info, err := client.DeployOVATemplate(ova)
+	i.Name = info.TemplateName
+	i.Preflight = info.Preflight
+	i.Kind = info.Kind
+	i.Drift = info.Drift
+	i.Source = info.Source
+	i.SmokeTest = info.SmokeTest
+	i.Replicas = info.Replicas
+	i.LibraryItem = info.LibraryItem
 	if err != nil {
 		return err
 	}
 	i.AlreadyExists = info.AlreadyExists
-	i.Name = info.TemplateName
+	i.StoragePolicyID = info.StoragePolicyID
+	i.KeyProvider = info.KeyProvider
+	i.NICs = info.NICs
+	i.Replaced = info.Replaced
+	i.Family = info.Family
+	i.Version = info.Version
+	if info.Snapshot != nil {
+		i.Snapshot = info.Snapshot.Value
+	}
+	i.EULAHash = info.EULAHash
 	i.Success = true
 	return err
 }
 
-func (i *importerResponse) response(err error) {
-	r := i.ToLogrusFields()
+// setComputeTargets finds the cluster, host and resource pool of the import. Without --resource-pool the
+// root pool of the host or cluster is used, and without any of them the datacenter's default pool.
+func setComputeTargets(client *vsphere.Session) error {
+	var err error
+	if cluster != "" {
+		client.Cluster, err = client.GetClusterOrDefault(cluster)
+		if err != nil {
+			return err
+		}
+	}
+	if host != "" {
+		client.Host, err = client.GetHostOrDefault(host)
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case resourcePool != "":
+		client.ResourcePool, err = client.GetResourcePoolOrDefault(resourcePool)
+	case client.Host != nil:
+		client.ResourcePool, err = client.Host.ResourcePool(client.Ctx)
+	case client.Cluster != nil:
+		client.ResourcePool, err = client.Cluster.ResourcePool(client.Ctx)
+	default:
+		// resource pool is need for the upload but doesnt really matter so we use the default
+		client.ResourcePool, err = client.GetResourcePoolOrDefault("")
+	}
+	if err != nil {
+		return err
+	}
+	return client.ValidateComputeTargets()
+}
+
+// connect logs in to the vCenter and finds the datacenter. The connection flags are checked here,
+// instead of being marked as required, so that subcommands without a vCenter (inspect) can run.
+func connect(ctx context.Context) (*vsphere.Session, error) {
+	var missing []string
+	for _, f := range []struct{ name, value string }{{"url", url}, {"user", user}, {"password", password}} {
+		if f.value == "" {
+			missing = append(missing, strconv.Quote(f.name))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, errors.New(fmt.Sprintf("required flag(s) %v not set", strings.Join(missing, ", ")))
+	}
+	return connectTo(ctx, url, user, password, datacenter)
+}
+
+// connectTo logs in to a vCenter and finds the datacenter
+func connectTo(ctx context.Context, url, user, password, datacenter string) (*vsphere.Session, error) {
+	client, err := vsphere.NewClient(ctx, url, user, password)
+	if err != nil {
+		return nil, err
+	}
+	client.Datacenter, err = client.GetDatacenterOrDefault(datacenter)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// reconfig reads the --reconfig-file and overrides it with the reconfiguration flags that are set
+func reconfig(flags *pflag.FlagSet) (vsphere.Reconfig, error) {
+	var r vsphere.Reconfig
+	if reconfigFile != "" {
+		var err error
+		if r, err = vsphere.ReadReconfig(reconfigFile); err != nil {
+			return r, err
+		}
+	}
+	if flags.Changed("num-cpus") {
+		r.NumCPUs = numCPUs
+	}
+	if flags.Changed("cores-per-socket") {
+		r.CoresPerSocket = coresPerSocket
+	}
+	if flags.Changed("memory-mb") {
+		r.MemoryMB = memoryMB
+	}
+	if flags.Changed("enable-disk-uuid") {
+		r.EnableDiskUUID = enableDiskUUID
+	}
+	if flags.Changed("firmware") {
+		r.Firmware = firmware
+	}
+	if flags.Changed("secure-boot") {
+		r.SecureBoot = &secureBoot
+	}
+	for label, size := range diskSizeGB {
+		if r.DiskSizeGB == nil {
+			r.DiskSizeGB = make(map[string]int64)
+		}
+		r.DiskSizeGB[label] = size
+	}
+	for k, v := range extraConfig {
+		if r.ExtraConfig == nil {
+			r.ExtraConfig = make(map[string]string)
+		}
+		r.ExtraConfig[k] = v
+	}
+	return r, nil
+}
+
+// guestInfoValues returns the --guestinfo values with the contents of the --guestinfo-userdata and --guestinfo-metadata files
+func guestInfoValues() (map[string]string, error) {
+	values := make(map[string]string, len(guestInfo)+2)
+	for k, v := range guestInfo {
+		values[k] = v
+	}
+	for key, file := range map[string]string{vsphere.GuestInfoUserData: guestInfoUserData, vsphere.GuestInfoMetaData: guestInfoMetaData} {
+		if file == "" {
+			continue
+		}
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read guestinfo %v file", key)
+		}
+		values[key] = string(data)
+	}
+	return values, nil
+}
+
+// countSet returns how many of the values are not empty
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+func response(resp responder, err error) {
+	r := resp.ToLogrusFields()
 	r["responseFile"] = path.Join(responseFileDirectory, responseFileName)
 	if err != nil {
 		r["errorMsg"] = err.Error()
@@ -167,7 +479,8 @@ func initConfig() {
 	viper.SetEnvPrefix(appName)
 
 	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+		// stdout is the OVA of export --output -
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 	postInitCommands([]*cobra.Command{rootCmd})
 }
@@ -200,6 +513,7 @@ func initLogging() {
 		}
 
 	}
+	responseFile = respFile
 	mw := io.MultiWriter(os.Stdout, respFile)
 	log.SetOutput(mw)
 }
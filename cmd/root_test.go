@@ -0,0 +1,61 @@
+// +build !integration
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureOutput returns what f writes to stdout and stderr
+func captureOutput(t *testing.T, f func()) (string, string) {
+	t.Helper()
+	read := func(target **os.File) func() string {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		original := *target
+		*target = w
+		done := make(chan string)
+		go func() {
+			b, _ := ioutil.ReadAll(r)
+			done <- string(b)
+		}()
+		return func() string {
+			*target = original
+			w.Close()
+			return <-done
+		}
+	}
+	stdout, stderr := read(&os.Stdout), read(&os.Stderr)
+	f()
+	return stdout(), stderr()
+}
+
+// The simulator has no ExportVm, so export --output - can't be run end to end. Loading the config file is what runs
+// before the OVA is written to stdout, it must leave stdout empty for the archive to start at the first byte.
+func TestInitConfigKeepsStdoutClean(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ovaimporter-config-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	config := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(config, []byte("url: vcenter.example.com\nuser: administrator@vsphere.local\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfgFile = config
+	defer func() { cfgFile = "" }()
+
+	stdout, stderr := captureOutput(t, initConfig)
+	if stdout != "" {
+		t.Fatalf("expected nothing on stdout, actual: %q", stdout)
+	}
+	if !strings.Contains(stderr, "Using config file: "+config) {
+		t.Fatalf("expected the config file on stderr, actual: %q", stderr)
+	}
+}
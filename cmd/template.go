@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/jacobweinstock/ovaimporter/pkg/vsphere"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	templateTag        string
+	templateAnnotation string
+	templateForce      bool
+	templateToFolder   string
+	templateNewName    string
+
+	templateCmd = &cobra.Command{
+		Use:   "template",
+		Short: "list, describe, delete, move and rename templates",
+	}
+	templateListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "list the templates in --folder, filtered by --tag and --annotation",
+		Run:   runTemplateCmd((*templateResponse).list),
+	}
+	templateDescribeCmd = &cobra.Command{
+		Use:   "describe",
+		Short: "describe the template --name, with its metadata, tags and linked clones",
+		Run:   runTemplateCmd((*templateResponse).describe),
+	}
+	templateDeleteCmd = &cobra.Command{
+		Use:   "delete",
+		Short: "delete the template --name, unless it has linked clones",
+		Run:   runTemplateCmd((*templateResponse).delete),
+	}
+	templateMoveCmd = &cobra.Command{
+		Use:   "move",
+		Short: "move the template --name to --to-folder",
+		Run:   runTemplateCmd((*templateResponse).move),
+	}
+	templateRenameCmd = &cobra.Command{
+		Use:   "rename",
+		Short: "rename the template --name to --new-name",
+		Run:   runTemplateCmd((*templateResponse).rename),
+	}
+)
+
+func init() {
+	templateListCmd.Flags().StringVar(&templateTag, "tag", "", "only list templates with this tag (category/tag)")
+	templateListCmd.Flags().StringVar(&templateAnnotation, "annotation", "", "only list templates whose annotation contains this text, an ovaimporter.<key>=<value> line must match exactly (e.g. ovaimporter.family=ubuntu)")
+	templateDeleteCmd.Flags().BoolVar(&templateForce, "force", false, "delete the template even when it has linked clones")
+	templateMoveCmd.Flags().StringVar(&templateToFolder, "to-folder", "", "folder to move the template to")
+	_ = templateMoveCmd.MarkFlagRequired("to-folder")
+	templateRenameCmd.Flags().StringVar(&templateNewName, "new-name", "", "new name of the template")
+	_ = templateRenameCmd.MarkFlagRequired("new-name")
+	templateCmd.AddCommand(templateListCmd, templateDescribeCmd, templateDeleteCmd, templateMoveCmd, templateRenameCmd)
+	rootCmd.AddCommand(templateCmd)
+}
+
+// runTemplateCmd connects to the vCenter, runs a template subcommand and writes its response
+func runTemplateCmd(run func(*templateResponse, *vsphere.Session) error) func(*cobra.Command, []string) {
+	return func(cmd *cobra.Command, args []string) {
+		var t templateResponse
+		err := t.run(run)
+		response(t, err)
+	}
+}
+
+func (t *templateResponse) run(run func(*templateResponse, *vsphere.Session) error) error {
+	tout := time.Duration(timeout) * time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), tout)
+	defer cancel()
+
+	client, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	t.Templates = []vsphere.TemplateInfo{}
+	if err := run(t, client); err != nil {
+		return err
+	}
+	t.Success = true
+	return nil
+}
+
+func (t *templateResponse) list(client *vsphere.Session) error {
+	filter := vsphere.TemplateFilter{Tag: templateTag, Annotation: templateAnnotation}
+	if folder != "" {
+		var err error
+		if filter.Folder, err = client.GetFolderOrDefault(folder); err != nil {
+			return err
+		}
+	}
+	templates, err := client.ListTemplates(filter)
+	if err != nil {
+		return err
+	}
+	t.Templates = templates
+	return nil
+}
+
+func (t *templateResponse) describe(client *vsphere.Session) error {
+	if name == "" {
+		return errors.New("required flag \"name\" not set")
+	}
+	info, err := client.DescribeTemplate(name)
+	if err != nil {
+		return err
+	}
+	t.Templates = append(t.Templates, info)
+	return nil
+}
+
+func (t *templateResponse) delete(client *vsphere.Session) error {
+	if name == "" {
+		return errors.New("required flag \"name\" not set")
+	}
+	info, err := client.DeleteTemplate(name, templateForce)
+	if info.ID != "" {
+		t.Templates = append(t.Templates, info)
+	}
+	return err
+}
+
+func (t *templateResponse) move(client *vsphere.Session) error {
+	if name == "" {
+		return errors.New("required flag \"name\" not set")
+	}
+	to, err := client.GetFolderOrDefault(templateToFolder)
+	if err != nil {
+		return err
+	}
+	info, err := client.MoveTemplate(name, to)
+	if err != nil {
+		return err
+	}
+	t.Templates = append(t.Templates, info)
+	return nil
+}
+
+func (t *templateResponse) rename(client *vsphere.Session) error {
+	if name == "" {
+		return errors.New("required flag \"name\" not set")
+	}
+	info, err := client.RenameTemplate(name, templateNewName)
+	if err != nil {
+		return err
+	}
+	t.Templates = append(t.Templates, info)
+	return nil
+}
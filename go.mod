@@ -11,4 +11,5 @@ require (
 	github.com/spf13/viper v1.7.1
 	github.com/vmware/govmomi v0.23.1
 	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -4,11 +4,13 @@ import (
 	"context"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/rest"
 
 	"github.com/vmware/govmomi"
 )
@@ -20,8 +22,15 @@ type Session struct {
 	Datastore    *object.Datastore
 	Folder       *object.Folder
 	ResourcePool *object.ResourcePool
+	Cluster      *object.ClusterComputeResource
+	Host         *object.HostSystem
 	Network      object.NetworkReference
+	Options      DeployOptions
 	Ctx          context.Context
+
+	user    *url.Userinfo
+	restMu  sync.Mutex
+	restCon *rest.Client
 }
 
 // NewClient returns a new vsphere Session
@@ -51,10 +60,16 @@ func NewClient(ctx context.Context, server string, username string, password str
 	}
 	sm.Conn = client
 	sm.Ctx = ctx
+	sm.user = authenticatedURL.User
 
 	return sm, nil
 }
 
+// IsESXi is true when the session is connected directly to a standalone ESXi host instead of a vCenter
+func (s *Session) IsESXi() bool {
+	return s.Conn.ServiceContent.About.ApiType == "HostAgent"
+}
+
 // GetDatacenterOrDefault returns the govmomi object for a datacenter
 func (s *Session) GetDatacenterOrDefault(name string) (*object.Datacenter, error) {
 	finder := find.NewFinder(s.Conn.Client, true)
@@ -76,6 +91,17 @@ func (s *Session) GetDatastoreOrDefault(name string) (*object.Datastore, error)
 	return datastore, err
 }
 
+// GetDatastoreClusterOrDefault returns the govmomi object for a datastore cluster (storage pod)
+func (s *Session) GetDatastoreClusterOrDefault(name string) (*object.StoragePod, error) {
+	finder := find.NewFinder(s.Conn.Client, true)
+	finder.SetDatacenter(s.Datacenter)
+	pod, err := finder.DatastoreClusterOrDefault(s.Ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding datastore cluster %s", name)
+	}
+	return pod, err
+}
+
 // GetNetworkOrDefault returns the govmomi object for a network
 func (s *Session) GetNetworkOrDefault(name string) (object.NetworkReference, error) {
 	finder := find.NewFinder(s.Conn.Client, true)
@@ -98,6 +124,28 @@ func (s *Session) GetResourcePoolOrDefault(name string) (*object.ResourcePool, e
 	return resourcePool, err
 }
 
+// GetClusterOrDefault returns the govmomi object for a cluster
+func (s *Session) GetClusterOrDefault(name string) (*object.ClusterComputeResource, error) {
+	finder := find.NewFinder(s.Conn.Client, true)
+	finder.SetDatacenter(s.Datacenter)
+	cluster, err := finder.ClusterComputeResourceOrDefault(s.Ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding cluster %s", name)
+	}
+	return cluster, err
+}
+
+// GetHostOrDefault returns the govmomi object for a host
+func (s *Session) GetHostOrDefault(name string) (*object.HostSystem, error) {
+	finder := find.NewFinder(s.Conn.Client, true)
+	finder.SetDatacenter(s.Datacenter)
+	host, err := finder.HostSystemOrDefault(s.Ctx, name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding host %s", name)
+	}
+	return host, err
+}
+
 // GetVM returns the govmomi object for a virtual machine
 func (s *Session) GetVM(name string) (*object.VirtualMachine, error) {
 	finder := find.NewFinder(s.Conn.Client, true)
@@ -119,3 +167,18 @@ func (s *Session) GetFolderOrDefault(name string) (*object.Folder, error) {
 	}
 	return desiredFolder, err
 }
+
+// RestClient returns a vAPI client, used for tags and content libraries, that is logged in on first use
+func (s *Session) RestClient() (*rest.Client, error) {
+	s.restMu.Lock()
+	defer s.restMu.Unlock()
+	if s.restCon != nil {
+		return s.restCon, nil
+	}
+	c := rest.NewClient(s.Conn.Client)
+	if err := c.Login(s.Ctx, s.user); err != nil {
+		return nil, errors.Wrap(err, "unable to login to the vSphere API endpoint")
+	}
+	s.restCon = c
+	return c, nil
+}
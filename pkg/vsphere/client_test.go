@@ -11,7 +11,9 @@ import (
 	"testing"
 	"time"
 
+	_ "github.com/vmware/govmomi/pbm/simulator"
 	"github.com/vmware/govmomi/simulator"
+	_ "github.com/vmware/govmomi/vapi/simulator"
 )
 
 var sim struct {
@@ -62,6 +64,7 @@ func setupSimulator() error {
 	model.Service.TLS = &tls.Config{
 		RootCAs: roots,
 	}
+	model.Service.RegisterEndpoints = true
 	server := model.Service.NewServer()
 	username := server.URL.User.Username()
 	password, _ := server.URL.User.Password()
@@ -208,6 +211,54 @@ func TestGetResourcePoolNotFound(t *testing.T) {
 	}
 }
 
+func TestGetCluster(t *testing.T) {
+	name := "/DC0/host/DC0_C0"
+	obj, err := sim.conn.GetClusterOrDefault(name)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if obj.InventoryPath != name {
+		t.Fatalf("expected: %v, actual: %v", name, obj.InventoryPath)
+	}
+}
+
+func TestGetClusterNotFound(t *testing.T) {
+	name := "/DC0/host/i_dont_exist"
+	errMsg := fmt.Sprintf("error finding cluster %v: cluster '%[1]v' not found", name)
+
+	_, err := sim.conn.GetClusterOrDefault(name)
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if err.Error() != errMsg {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err.Error())
+	}
+}
+
+func TestGetHost(t *testing.T) {
+	name := "/DC0/host/DC0_C0/DC0_C0_H0"
+	obj, err := sim.conn.GetHostOrDefault(name)
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+	if obj.InventoryPath != name {
+		t.Fatalf("expected: %v, actual: %v", name, obj.InventoryPath)
+	}
+}
+
+func TestGetHostNotFound(t *testing.T) {
+	name := "/DC0/host/DC0_C0/i_dont_exist"
+	errMsg := fmt.Sprintf("error finding host %v: host '%[1]v' not found", name)
+
+	_, err := sim.conn.GetHostOrDefault(name)
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if err.Error() != errMsg {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err.Error())
+	}
+}
+
 func TestGetVM(t *testing.T) {
 	name := "DC0_H0_VM1"
 	obj, err := sim.conn.GetVM(name)
@@ -0,0 +1,281 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/nfc"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// copyProgressInterval is how often the progress of a disk of unknown size is reported
+const copyProgressInterval = 64 << 20
+
+// CopyOptions changes how Copy copies a template or VM
+type CopyOptions struct {
+	// Name of the copy, defaults to the name of the source
+	Name string
+	// Progress is called as the disks are transferred, at every percent or every 64MB when the size isn't known
+	Progress func(CopyProgress)
+}
+
+// CopyProgress is the progress of the transfer of a disk
+type CopyProgress struct {
+	File        string `json:"file"`
+	Transferred int64  `json:"transferred"`
+	// Total is -1 when the source didn't send the size of the disk
+	Total int64 `json:"total"`
+}
+
+// CopyFile is a disk transferred by Copy
+type CopyFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+	Sha1   string `json:"sha1"`
+	// Verified is set when the hash matches the one the source computed for the export
+	Verified bool `json:"verified"`
+}
+
+// CopyResult describes the copy of a template or VM on the target
+type CopyResult struct {
+	Name          string     `json:"name"`
+	ID            string     `json:"id,omitempty"`
+	Kind          string     `json:"kind"`
+	AlreadyExists bool       `json:"alreadyExists"`
+	Files         []CopyFile `json:"files"`
+}
+
+// Copy copies a template or VM to the folder, resource pool, datastore and network of the target session, which is
+// usually on another vCenter. Each disk is downloaded from an export lease on the source and uploaded to an import
+// lease on the target as it's read, only a disk the source doesn't send the size of is staged on disk. The hashes of the disks are checked against the
+// manifest of the export lease when the source provides one, a copy that doesn't match is deleted.
+func (s *Session) Copy(vm *object.VirtualMachine, target *Session, opts CopyOptions) (CopyResult, error) {
+	ctx := s.Ctx
+	var props mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"name", "config.template"}, &props); err != nil {
+		return CopyResult{}, errors.Wrapf(err, "unable to get the properties of %v", vm.Reference())
+	}
+	name := opts.Name
+	if name == "" {
+		name = props.Name
+	}
+	name, err := sanitizeName(name)
+	if err != nil {
+		return CopyResult{}, err
+	}
+	result := CopyResult{Name: name, Kind: KindVM, Files: []CopyFile{}}
+	if props.Config != nil && props.Config.Template {
+		result.Kind = KindTemplate
+	}
+	existing, err := target.findExisting(ctx, name)
+	if err != nil {
+		return result, err
+	}
+	if existing != nil {
+		result.AlreadyExists = true
+		result.ID = existing.Reference().Value
+		return result, nil
+	}
+
+	lease, err := vm.Export(ctx)
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to export %v", props.Name)
+	}
+	info, err := lease.Wait(ctx, nil)
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to export %v", props.Name)
+	}
+	u := lease.StartUpdater(ctx, info)
+	defer u.Done()
+
+	disks := make(map[string]nfc.FileItem)
+	params := types.OvfCreateDescriptorParams{Name: name}
+	for _, item := range info.Items {
+		if path.Ext(item.Path) != ".vmdk" {
+			continue
+		}
+		item.Path = exportDiskName(name, item.Path)
+		disks[item.Path] = item
+		params.OvfFiles = append(params.OvfFiles, item.File())
+	}
+	desc, err := ovf.NewManager(s.Conn.Client).CreateDescriptor(ctx, vm, params)
+	if err != nil {
+		_ = lease.Abort(ctx, nil)
+		return result, errors.Wrapf(err, "unable to create the OVF descriptor of %v", props.Name)
+	}
+	if desc.Error != nil {
+		_ = lease.Abort(ctx, nil)
+		return result, errors.New(fmt.Sprintf("unable to create the OVF descriptor of %v, %v", props.Name, desc.Error[0].LocalizedMessage))
+	}
+
+	open := func(ctx context.Context, file string) (io.ReadCloser, int64, error) {
+		item, ok := disks[file]
+		if !ok {
+			return nil, 0, errors.New(fmt.Sprintf("%v isn't a disk of the export of %v", file, props.Name))
+		}
+		r, size, err := s.Conn.Client.Download(ctx, item.URL, &soap.Download{Progress: item})
+		return r, size, errors.Wrapf(err, "unable to download %v", file)
+	}
+	copied, files, err := target.importStream(ctx, name, desc.OvfDescriptor, open, opts.Progress)
+	result.Files = files
+	if err != nil {
+		_ = lease.Abort(ctx, nil)
+		return result, err
+	}
+	result.ID = copied.Reference().Value
+
+	manifest, err := methods.HttpNfcLeaseGetManifest(ctx, s.Conn.Client, &types.HttpNfcLeaseGetManifest{This: lease.Reference()})
+	if err == nil {
+		if err := verifyManifest(manifest.Returnval, disks, result.Files); err != nil {
+			_ = lease.Abort(ctx, nil)
+			if cleanupErr := destroyVM(ctx, copied); cleanupErr != nil {
+				return result, errors.WithMessagef(err, "the copy %v could not be deleted (%v)", result.ID, cleanupErr)
+			}
+			result.ID = ""
+			return result, errors.WithMessage(err, "the copy was deleted")
+		}
+	}
+	if err := lease.Complete(ctx); err != nil {
+		return result, errors.Wrapf(err, "unable to complete the export of %v", props.Name)
+	}
+	if result.Kind == KindTemplate {
+		if err := copied.MarkAsTemplate(ctx); err != nil {
+			return result, errors.Wrapf(err, "unable to mark %v as a template", name)
+		}
+	}
+	return result, nil
+}
+
+// importStream imports an OVF descriptor, the disks are read from open as they're uploaded. The import lease needs
+// the size of a disk, a disk open returns no size for is spooled to a temporary file first.
+func (s *Session) importStream(ctx context.Context, name string, descriptor string, open func(context.Context, string) (io.ReadCloser, int64, error), progress func(CopyProgress)) (*object.VirtualMachine, []CopyFile, error) {
+	files := []CopyFile{}
+	if s.Network == nil {
+		return nil, files, errors.New("a network is needed to import the copy")
+	}
+	env, err := ovf.Unmarshal(strings.NewReader(descriptor))
+	if err != nil {
+		return nil, files, errors.Wrap(err, "unable to parse the OVF descriptor")
+	}
+	cisp := s.importSpecParams(name)
+	if env.Network != nil && len(env.Network.Networks) > 0 {
+		cisp.NetworkMapping = nil
+		for _, network := range env.Network.Networks {
+			cisp.NetworkMapping = append(cisp.NetworkMapping, types.OvfNetworkMapping{Name: network.Name, Network: s.Network.Reference()})
+		}
+	}
+	spec, err := ovf.NewManager(s.Conn.Client).CreateImportSpec(ctx, descriptor, s.ResourcePool, s.Datastore, cisp)
+	if err != nil {
+		return nil, files, errors.Wrapf(err, "unable to create import spec for %v", name)
+	}
+	if err := prepareImportSpec(spec, nil); err != nil {
+		return nil, files, err
+	}
+	vm, err := importVApp(ctx, s, spec, func(lease *nfc.Lease, item nfc.FileItem) error {
+		r, size, err := open(ctx, item.Path)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		t := newTransferReader(r, item.Path, size, progress)
+		var body io.Reader = t
+		if size < 0 {
+			f, err := spool(t)
+			if err != nil {
+				files = append(files, t.result())
+				return errors.WithMessagef(err, "unable to download %v", item.Path)
+			}
+			defer f.Close()
+			body, size = f, f.size
+		}
+		err = lease.Upload(ctx, item, body, soap.Upload{ContentLength: size})
+		files = append(files, t.result())
+		return errors.Wrapf(err, "unable to upload %v", item.Path)
+	})
+	return vm, files, err
+}
+
+// verifyManifest checks the transferred disks against the manifest of the export lease, entries are keyed by device
+func verifyManifest(manifest []types.HttpNfcLeaseManifestEntry, disks map[string]nfc.FileItem, files []CopyFile) error {
+	entries := make(map[string]types.HttpNfcLeaseManifestEntry, len(manifest))
+	for _, entry := range manifest {
+		entries[entry.Key] = entry
+	}
+	for i, file := range files {
+		entry, ok := entries[disks[file.Name].DeviceId]
+		if !ok {
+			continue
+		}
+		expected, actual := entry.Sha1, file.Sha1
+		if strings.EqualFold(entry.ChecksumType, "sha256") && entry.Checksum != "" {
+			expected, actual = entry.Checksum, file.Sha256
+		}
+		if !strings.EqualFold(expected, actual) {
+			return errors.New(fmt.Sprintf("%v was corrupted in transit, expected hash %v, actual: %v", file.Name, expected, actual))
+		}
+		files[i].Verified = true
+	}
+	return nil
+}
+
+// transferReader hashes and counts what is read through it and reports the progress
+type transferReader struct {
+	r        io.Reader
+	name     string
+	total    int64
+	read     int64
+	reported int64
+	sha256   hash.Hash
+	sha1     hash.Hash
+	progress func(CopyProgress)
+}
+
+func newTransferReader(r io.Reader, name string, total int64, progress func(CopyProgress)) *transferReader {
+	return &transferReader{r: r, name: name, total: total, sha256: sha256.New(), sha1: sha1.New(), progress: progress}
+}
+
+func (t *transferReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+	if n > 0 {
+		_, _ = t.sha256.Write(p[:n])
+		_, _ = t.sha1.Write(p[:n])
+		t.read += int64(n)
+	}
+	if t.progress == nil {
+		return n, err
+	}
+	step := int64(copyProgressInterval)
+	if t.total > 0 {
+		step = t.total / 100
+	}
+	done := err == io.EOF || (t.total > 0 && t.read >= t.total)
+	if t.read != t.reported && (t.read-t.reported >= step || done) {
+		t.reported = t.read
+		t.progress(CopyProgress{File: t.name, Transferred: t.read, Total: t.total})
+	}
+	return n, err
+}
+
+func (t *transferReader) result() CopyFile {
+	return CopyFile{
+		Name:   t.name,
+		Size:   t.read,
+		Sha256: hex.EncodeToString(t.sha256.Sum(nil)),
+		Sha1:   hex.EncodeToString(t.sha1.Sum(nil)),
+	}
+}
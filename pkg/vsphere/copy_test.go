@@ -0,0 +1,119 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/nfc"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// The simulator has no ExportVm, so the export lease of Copy isn't tested end to end. TestImportStream covers the
+// import side, TestVerifyManifest the manifest check and TestCopyAlreadyExists what happens before the export.
+func TestImportStream(t *testing.T) {
+	tests := map[string]struct {
+		size int64
+	}{
+		"known size":   {512},
+		"unknown size": {-1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useTestTargets(t)
+			vmName := "stream-tiny-" + strings.Replace(name, " ", "-", -1)
+			var descriptor bytes.Buffer
+			if err := testOVFTemplate.Execute(&descriptor, testOVF{Name: vmName, OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"}); err != nil {
+				t.Fatal(err)
+			}
+			disk := bytes.Repeat([]byte{1}, 512)
+			open := func(ctx context.Context, file string) (io.ReadCloser, int64, error) {
+				if file != "disk-0.vmdk" {
+					return nil, 0, fmt.Errorf("unexpected file %v", file)
+				}
+				return ioutil.NopCloser(bytes.NewReader(disk)), tc.size, nil
+			}
+			var reports []CopyProgress
+			progress := func(p CopyProgress) { reports = append(reports, p) }
+
+			vm, files, err := sim.conn.importStream(context.Background(), vmName, descriptor.String(), open, progress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := sim.conn.GetVM(vmName); err != nil || vm == nil {
+				t.Fatalf("expected %v to be imported, %v", vmName, err)
+			}
+			sum := sha256.Sum256(disk)
+			if len(files) != 1 || files[0].Size != 512 || files[0].Sha256 != fmt.Sprintf("%x", sum) {
+				t.Fatalf("unexpected files %+v", files)
+			}
+			if len(reports) == 0 || reports[len(reports)-1] != (CopyProgress{File: "disk-0.vmdk", Transferred: 512, Total: tc.size}) {
+				t.Fatalf("expected the transfer to be reported as done, actual: %+v", reports)
+			}
+		})
+	}
+}
+
+func TestCopyAlreadyExists(t *testing.T) {
+	useTestTargets(t)
+	vm, err := sim.conn.GetVM("DC0_H0_VM0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := sim.conn.Copy(vm, sim.conn, CopyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.AlreadyExists || result.ID != vm.Reference().Value || result.Kind != KindVM {
+		t.Fatalf("expected the existing VM to be returned, actual: %+v", result)
+	}
+}
+
+func TestVerifyManifest(t *testing.T) {
+	disk := []byte("disk")
+	sum1, sum256 := sha1.Sum(disk), sha256.Sum256(disk)
+	file := CopyFile{Name: "tpl-disk-0.vmdk", Sha1: fmt.Sprintf("%x", sum1), Sha256: fmt.Sprintf("%x", sum256)}
+	disks := map[string]nfc.FileItem{file.Name: {OvfFileItem: types.OvfFileItem{DeviceId: "/vm-1/VirtualLsiLogicController0:0"}}}
+	key := disks[file.Name].DeviceId
+	tests := map[string]struct {
+		manifest []types.HttpNfcLeaseManifestEntry
+		verified bool
+		err      bool
+	}{
+		"sha1":            {[]types.HttpNfcLeaseManifestEntry{{Key: key, Sha1: strings.ToUpper(file.Sha1)}}, true, false},
+		"sha256":          {[]types.HttpNfcLeaseManifestEntry{{Key: key, Sha1: "ignored", Checksum: file.Sha256, ChecksumType: "SHA256"}}, true, false},
+		"corrupted":       {[]types.HttpNfcLeaseManifestEntry{{Key: key, Sha1: "0000"}}, false, true},
+		"not in manifest": {[]types.HttpNfcLeaseManifestEntry{{Key: "other", Sha1: "0000"}}, false, false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			files := []CopyFile{file}
+			err := verifyManifest(tc.manifest, disks, files)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %v, actual: %v", tc.err, err)
+			}
+			if files[0].Verified != tc.verified {
+				t.Fatalf("expected verified: %v, actual: %v", tc.verified, files[0].Verified)
+			}
+		})
+	}
+}
+
+func TestTransferReaderUnknownSize(t *testing.T) {
+	var reports []CopyProgress
+	r := newTransferReader(strings.NewReader("disk"), "disk-0.vmdk", -1, func(p CopyProgress) { reports = append(reports, p) })
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].Transferred != 4 || reports[0].Total != -1 || r.result().Size != 4 {
+		t.Fatalf("expected one report at the end, actual: %+v", reports)
+	}
+}
@@ -0,0 +1,221 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Datastore selection policies, regex and tag take an argument after the colon
+const (
+	DatastoreSelectMostFree  = "most-free"
+	DatastoreSelectLeastUsed = "least-used"
+	DatastoreSelectRegex     = "regex:"
+	DatastoreSelectTag       = "tag:"
+)
+
+// DatastoreSelection describes which datastore SelectDatastore picked and why
+type DatastoreSelection struct {
+	Name       string  `json:"name"`
+	Policy     string  `json:"policy"`
+	Reason     string  `json:"reason"`
+	Candidates int     `json:"candidates"`
+	FreeGB     float64 `json:"freeGB"`
+	CapacityGB float64 `json:"capacityGB"`
+}
+
+// SelectDatastore picks a datastore of the datacenter by policy: most-free, least-used, regex:<pattern> or tag:<category/tag>.
+// Datastores in maintenance mode, inaccessible ones and those not mounted on a host of the resource pool are never picked.
+func (s *Session) SelectDatastore(policy string) (*object.Datastore, DatastoreSelection, error) {
+	selection := DatastoreSelection{Policy: policy}
+	var filter func(datastoreCandidate) bool
+	byUsage := false
+	switch {
+	case policy == DatastoreSelectMostFree:
+	case policy == DatastoreSelectLeastUsed:
+		byUsage = true
+	case strings.HasPrefix(policy, DatastoreSelectRegex):
+		re, err := regexp.Compile(strings.TrimPrefix(policy, DatastoreSelectRegex))
+		if err != nil {
+			return nil, selection, errors.Wrapf(err, "invalid datastore selection policy %v", policy)
+		}
+		filter = func(ds datastoreCandidate) bool { return re.MatchString(ds.Name) }
+	case strings.HasPrefix(policy, DatastoreSelectTag):
+		tagged, err := s.taggedObjects(strings.TrimPrefix(policy, DatastoreSelectTag))
+		if err != nil {
+			return nil, selection, err
+		}
+		filter = func(ds datastoreCandidate) bool { return tagged[ds.Reference()] }
+	default:
+		return nil, selection, errors.New(fmt.Sprintf("unknown datastore selection policy %v", policy))
+	}
+
+	candidates, err := s.datastoreCandidates(s.Ctx)
+	if err != nil {
+		return nil, selection, err
+	}
+	var eligible []datastoreCandidate
+	for _, ds := range candidates {
+		if filter == nil || filter(ds) {
+			eligible = append(eligible, ds)
+		}
+	}
+	selection.Candidates = len(eligible)
+	if len(eligible) == 0 {
+		return nil, selection, errors.New(fmt.Sprintf("no usable datastore matches selection policy %v", policy))
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		a, b := eligible[i].Summary, eligible[j].Summary
+		if byUsage && usedRatio(a) != usedRatio(b) {
+			return usedRatio(a) < usedRatio(b)
+		}
+		if !byUsage && a.FreeSpace != b.FreeSpace {
+			return a.FreeSpace > b.FreeSpace
+		}
+		return a.Name < b.Name
+	})
+	chosen := eligible[0]
+	selection.Name = chosen.Name
+	selection.FreeGB = float64(chosen.Summary.FreeSpace) / (1 << 30)
+	selection.CapacityGB = float64(chosen.Summary.Capacity) / (1 << 30)
+	if byUsage {
+		selection.Reason = fmt.Sprintf("least used datastore (%.1f%% used) of %d candidates", usedRatio(chosen.Summary)*100, len(eligible))
+	} else {
+		selection.Reason = fmt.Sprintf("most free space (%.2fGB of %.2fGB) of %d candidates", selection.FreeGB, selection.CapacityGB, len(eligible))
+	}
+
+	return chosen.object, selection, nil
+}
+
+type datastoreCandidate struct {
+	mo.Datastore
+	object *object.Datastore
+}
+
+// datastoreCandidates returns the datastores of the datacenter that are accessible, not in maintenance mode
+// and mounted on at least one host of the session's resource pool
+func (s *Session) datastoreCandidates(ctx context.Context) ([]datastoreCandidate, error) {
+	finder := find.NewFinder(s.Conn.Client, true)
+	finder.SetDatacenter(s.Datacenter)
+	list, err := finder.DatastoreList(ctx, "*")
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing datastores")
+	}
+	hosts, err := s.placementHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make([]types.ManagedObjectReference, 0, len(list))
+	objects := make(map[types.ManagedObjectReference]*object.Datastore, len(list))
+	for _, ds := range list {
+		refs = append(refs, ds.Reference())
+		objects[ds.Reference()] = ds
+	}
+	var dss []mo.Datastore
+	pc := s.Conn.PropertyCollector()
+	if err := pc.Retrieve(ctx, refs, []string{"name", "summary", "host"}, &dss); err != nil {
+		return nil, errors.Wrap(err, "error retrieving datastore details")
+	}
+
+	var candidates []datastoreCandidate
+	for _, ds := range dss {
+		if !ds.Summary.Accessible {
+			continue
+		}
+		switch ds.Summary.MaintenanceMode {
+		case string(types.DatastoreSummaryMaintenanceModeStateInMaintenance), string(types.DatastoreSummaryMaintenanceModeStateEnteringMaintenance):
+			continue
+		}
+		if !mountedOnAny(ds, hosts) {
+			continue
+		}
+		candidates = append(candidates, datastoreCandidate{Datastore: ds, object: objects[ds.Reference()]})
+	}
+	return candidates, nil
+}
+
+// placementHosts returns the hosts on which the import can be placed, the session's host or those of the resource pool's owner.
+// Without a host or resource pool every host is acceptable and nil is returned.
+func (s *Session) placementHosts(ctx context.Context) ([]mo.HostSystem, error) {
+	if s.Host != nil {
+		return s.hostProperties(ctx, []types.ManagedObjectReference{s.Host.Reference()})
+	}
+	if s.ResourcePool == nil {
+		return nil, nil
+	}
+	owner, err := s.ResourcePool.Owner(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find owner of resource pool %v", s.ResourcePool.InventoryPath)
+	}
+	var cr mo.ComputeResource
+	pc := s.Conn.PropertyCollector()
+	if err := pc.RetrieveOne(ctx, owner.Reference(), []string{"host"}, &cr); err != nil {
+		return nil, errors.Wrapf(err, "unable to retrieve hosts of %v", owner.Reference())
+	}
+	return s.hostProperties(ctx, cr.Host)
+}
+
+// hostProperties returns the name, datastores and networks of hosts
+func (s *Session) hostProperties(ctx context.Context, refs []types.ManagedObjectReference) ([]mo.HostSystem, error) {
+	hosts := []mo.HostSystem{}
+	if len(refs) == 0 {
+		return hosts, nil
+	}
+	pc := s.Conn.PropertyCollector()
+	if err := pc.Retrieve(ctx, refs, []string{"name", "datastore", "network"}, &hosts); err != nil {
+		return nil, errors.Wrap(err, "unable to retrieve host details")
+	}
+	return hosts, nil
+}
+
+// mountedOnAny is true when one of the hosts lists the datastore and the datastore
+// doesn't report itself as unmounted or inaccessible on that host
+func mountedOnAny(ds mo.Datastore, hosts []mo.HostSystem) bool {
+	if hosts == nil {
+		return true
+	}
+	for _, host := range hosts {
+		if !containsRef(host.Datastore, ds.Reference()) {
+			continue
+		}
+		usable := true
+		for _, mount := range ds.Host {
+			if mount.Key != host.Reference() {
+				continue
+			}
+			info := mount.MountInfo
+			usable = (info.Mounted == nil || *info.Mounted) && (info.Accessible == nil || *info.Accessible)
+		}
+		if usable {
+			return true
+		}
+	}
+	return false
+}
+
+func containsRef(refs []types.ManagedObjectReference, ref types.ManagedObjectReference) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
+
+func usedRatio(summary types.DatastoreSummary) float64 {
+	if summary.Capacity == 0 {
+		return 1
+	}
+	return float64(summary.Capacity-summary.FreeSpace) / float64(summary.Capacity)
+}
@@ -0,0 +1,134 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vapi/tags"
+)
+
+// createTestDatastore adds a local datastore to a simulator host
+func createTestDatastore(t *testing.T, hostName string, name string) {
+	t.Helper()
+	finder := find.NewFinder(sim.conn.Conn.Client, true)
+	finder.SetDatacenter(sim.conn.Datacenter)
+	host, err := finder.HostSystem(sim.conn.Ctx, hostName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dss, err := host.ConfigManager().DatastoreSystem(sim.conn.Ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	if _, err := dss.CreateLocalDatastore(sim.conn.Ctx, name, dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectDatastoreMostFree(t *testing.T) {
+	useTestTargets(t)
+	createTestDatastore(t, "DC0_C0_H0", "ClusterOnlyDS")
+
+	ds, selection, err := sim.conn.SelectDatastore(DatastoreSelectMostFree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ClusterOnlyDS isn't mounted on the host of the resource pool
+	if selection.Name != "LocalDS_0" || ds.Name() != "LocalDS_0" {
+		t.Fatalf("expected: LocalDS_0, actual: %v", selection.Name)
+	}
+	if selection.Candidates != 1 {
+		t.Fatalf("expected 1 candidate, actual: %v", selection.Candidates)
+	}
+	if selection.Reason == "" {
+		t.Fatal("expected a reason for the selection")
+	}
+}
+
+func TestSelectDatastoreLeastUsed(t *testing.T) {
+	useTestTargets(t)
+	createTestDatastore(t, "DC0_H0", "AnotherLocalDS")
+
+	_, selection, err := sim.conn.SelectDatastore(DatastoreSelectLeastUsed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selection.Candidates != 2 {
+		t.Fatalf("expected 2 candidates, actual: %v", selection.Candidates)
+	}
+	// both datastores live on the same file system, ties are broken by name
+	if selection.Name != "AnotherLocalDS" {
+		t.Fatalf("expected: AnotherLocalDS, actual: %v", selection.Name)
+	}
+}
+
+func TestSelectDatastoreRegex(t *testing.T) {
+	useTestTargets(t)
+
+	_, selection, err := sim.conn.SelectDatastore("regex:^Local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selection.Name != "LocalDS_0" {
+		t.Fatalf("expected: LocalDS_0, actual: %v", selection.Name)
+	}
+
+	_, _, err = sim.conn.SelectDatastore("regex:^nothing")
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if err.Error() != "no usable datastore matches selection policy regex:^nothing" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestSelectDatastoreTag(t *testing.T) {
+	useTestTargets(t)
+	c, err := sim.conn.RestClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := tags.NewManager(c)
+	categoryID, err := m.CreateCategory(sim.conn.Ctx, &tags.Category{Name: "storage-tier", Cardinality: "SINGLE"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagID, err := m.CreateTag(sim.conn.Ctx, &tags.Tag{Name: "gold", CategoryID: categoryID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds, err := sim.conn.GetDatastoreOrDefault("/DC0/datastore/LocalDS_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AttachTag(sim.conn.Ctx, tagID, ds); err != nil {
+		t.Fatal(err)
+	}
+
+	_, selection, err := sim.conn.SelectDatastore("tag:storage-tier/gold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if selection.Name != "LocalDS_0" || selection.Candidates != 1 {
+		t.Fatalf("expected LocalDS_0 as the only candidate, actual: %+v", selection)
+	}
+}
+
+func TestSelectDatastoreUnknownPolicy(t *testing.T) {
+	_, _, err := sim.conn.SelectDatastore("biggest")
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if err.Error() != "unknown datastore selection policy biggest" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package vsphere
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/ovf"
+)
+
+// OVAInfo is data read from the OVF descriptor of an OVA
+type OVAInfo struct {
+	Name             string   `json:"name"`
+	Product          string   `json:"product,omitempty"`
+	Vendor           string   `json:"vendor,omitempty"`
+	Version          string   `json:"version,omitempty"`
+	HardwareVersions []string `json:"hardwareVersions,omitempty"`
+	GuestID          string   `json:"guestId,omitempty"`
+	EULA             []string `json:"eula,omitempty"`
+	EULAHash         string   `json:"eulaSha256,omitempty"`
+}
+
+// Inspect reads the OVF descriptor of a local or remote OVA, no vCenter connection is needed
+func Inspect(ovaPath string) (OVAInfo, error) {
+	var info OVAInfo
+	ovaClient, err := newOVA(nil, ovaPath)
+	if err != nil {
+		return info, errors.WithMessage(err, "unable to create ova client")
+	}
+	env, err := ovaClient.getEnvelope(ovaPath)
+	if err != nil {
+		return info, err
+	}
+	info.Name = ovaBaseName(ovaPath)
+	if product := ovfProduct(env); product != nil {
+		info.Product = product.Product
+		info.Vendor = product.Vendor
+		info.Version = product.Version
+	}
+	info.HardwareVersions = ovfHardwareVersions(env)
+	info.GuestID = ovfGuestID(env)
+	info.EULA = ovfEULAs(env)
+	info.EULAHash = eulaHash(info.EULA)
+	return info, nil
+}
+
+// readEnvelope returns the parsed OVF descriptor of an OVA
+func (s *Session) readEnvelope(ovaPath string) (*ovf.Envelope, error) {
+	ovaClient, err := newOVA(s.Conn, ovaPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to create ova client")
+	}
+	return ovaClient.getEnvelope(ovaPath)
+}
+
+// ovfProduct returns the first ProductSection of the OVF, if any
+func ovfProduct(env *ovf.Envelope) *ovf.ProductSection {
+	if env.VirtualSystem != nil && len(env.VirtualSystem.Product) > 0 {
+		return &env.VirtualSystem.Product[0]
+	}
+	return env.Product
+}
+
+// ovfEULAs returns the license text of every EulaSection in the OVF
+func ovfEULAs(env *ovf.Envelope) []string {
+	var licenses []string
+	if env.Eula != nil {
+		licenses = append(licenses, strings.TrimSpace(env.Eula.License))
+	}
+	if env.VirtualSystem != nil {
+		for _, e := range env.VirtualSystem.Eula {
+			licenses = append(licenses, strings.TrimSpace(e.License))
+		}
+	}
+	return licenses
+}
+
+// eulaHash is the hex encoded sha256 of all license texts, or empty if there are none
+func eulaHash(licenses []string) string {
+	if len(licenses) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.Join(licenses, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ovfDiskSize returns the bytes a disk needs on the datastore, thin provisioned disks only need their populated size
+func ovfDiskSize(disk ovf.VirtualDiskDesc, thin bool) (int64, error) {
+	if thin && disk.PopulatedSize != nil {
+		return int64(*disk.PopulatedSize), nil
+	}
+	capacity, err := strconv.ParseUint(disk.Capacity, 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to parse capacity of disk %v", disk.DiskID)
+	}
+	if disk.CapacityAllocationUnits == nil {
+		return int64(capacity), nil
+	}
+	// units are of the form "byte * 2^30"
+	units := strings.Fields(*disk.CapacityAllocationUnits)
+	if len(units) != 3 || units[0] != "byte" || units[1] != "*" {
+		return 0, errors.New(fmt.Sprintf("unsupported capacity allocation units %q of disk %v", *disk.CapacityAllocationUnits, disk.DiskID))
+	}
+	factor := strings.SplitN(units[2], "^", 2)
+	base, err := strconv.ParseUint(factor[0], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to parse capacity allocation units of disk %v", disk.DiskID)
+	}
+	multiplier := float64(base)
+	if len(factor) == 2 {
+		exp, err := strconv.ParseUint(factor[1], 10, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "unable to parse capacity allocation units of disk %v", disk.DiskID)
+		}
+		multiplier = math.Pow(float64(base), float64(exp))
+	}
+	return int64(float64(capacity) * multiplier), nil
+}
+
+// ovfRequiredSpace returns the bytes all disks of the OVF need on the datastore
+func ovfRequiredSpace(env *ovf.Envelope, thin bool) (int64, error) {
+	var total int64
+	if env.Disk == nil {
+		return total, nil
+	}
+	for _, disk := range env.Disk.Disks {
+		size, err := ovfDiskSize(disk, thin)
+		if err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	return total, nil
+}
@@ -0,0 +1,77 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/vmware/govmomi/ovf"
+)
+
+func TestInspect(t *testing.T) {
+	ovaPath := newTestOVA(t, testOVF{Name: "inspect-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13", EULA: "  no warranty  "})
+
+	info, err := Inspect(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "inspect-tiny" {
+		t.Fatalf("expected: inspect-tiny, actual: %v", info.Name)
+	}
+	if info.GuestID != "otherLinux64Guest" {
+		t.Fatalf("expected: otherLinux64Guest, actual: %v", info.GuestID)
+	}
+	if len(info.EULA) != 1 || info.EULA[0] != "no warranty" {
+		t.Fatalf("expected: [no warranty], actual: %v", info.EULA)
+	}
+	if info.EULAHash == "" {
+		t.Fatal("expected a EULA hash")
+	}
+}
+
+func TestInspectNoEULA(t *testing.T) {
+	ovaPath := newTestOVA(t, testOVF{Name: "inspect-no-eula"})
+
+	info, err := Inspect(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.EULA) != 0 || info.EULAHash != "" {
+		t.Fatalf("expected no EULA, actual: %v %v", info.EULA, info.EULAHash)
+	}
+}
+
+func TestOVFDiskSize(t *testing.T) {
+	populated := 2048
+	units := "byte * 2^20"
+	tests := []struct {
+		name     string
+		disk     ovf.VirtualDiskDesc
+		thin     bool
+		expected int64
+	}{
+		{"thin uses populated size", ovf.VirtualDiskDesc{Capacity: "10", CapacityAllocationUnits: &units, PopulatedSize: &populated}, true, 2048},
+		{"thick uses capacity", ovf.VirtualDiskDesc{Capacity: "10", CapacityAllocationUnits: &units, PopulatedSize: &populated}, false, 10 << 20},
+		{"thin without populated size", ovf.VirtualDiskDesc{Capacity: "10", CapacityAllocationUnits: &units}, true, 10 << 20},
+		{"bytes", ovf.VirtualDiskDesc{Capacity: "4096"}, false, 4096},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			size, err := ovfDiskSize(tc.disk, tc.thin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != tc.expected {
+				t.Fatalf("expected: %v, actual: %v", tc.expected, size)
+			}
+		})
+	}
+}
+
+func TestOVFDiskSizeUnsupportedUnits(t *testing.T) {
+	units := "sectors"
+	_, err := ovfDiskSize(ovf.VirtualDiskDesc{DiskID: "vmdisk1", Capacity: "10", CapacityAllocationUnits: &units}, false)
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+}
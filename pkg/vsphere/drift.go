@@ -0,0 +1,125 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+)
+
+// Drift of an existing template from its source OVA
+const (
+	DriftUpToDate = "upToDate"
+	DriftDrifted  = "drifted"
+	DriftUnknown  = "unknown"
+)
+
+// What happens to an existing template that drifted from its source OVA
+const (
+	OnDriftSkip    = "skip"
+	OnDriftFail    = "fail"
+	OnDriftReplace = "replace"
+)
+
+// Metadata keys of the source OVA stored in the annotation of an imported template
+const (
+	MetadataSourceDigest       = "sourceSha256"
+	MetadataSourceETag         = "sourceETag"
+	MetadataSourceLastModified = "sourceLastModified"
+)
+
+// SourceInfo identifies the contents of an OVA, it is recorded on the template to detect when the OVA changes
+type SourceInfo struct {
+	// Digest is the sha256 of the OVF descriptor and manifest
+	Digest       string `json:"sha256"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+}
+
+func (i SourceInfo) metadata() map[string]string {
+	return map[string]string{
+		MetadataSourceDigest:       i.Digest,
+		MetadataSourceETag:         i.ETag,
+		MetadataSourceLastModified: i.LastModified,
+	}
+}
+
+func sourceFromMetadata(metadata map[string]string) SourceInfo {
+	return SourceInfo{
+		Digest:       metadata[MetadataSourceDigest],
+		ETag:         metadata[MetadataSourceETag],
+		LastModified: metadata[MetadataSourceLastModified],
+	}
+}
+
+// drift compares the source recorded on a template with the current source. The digest is authoritative when both
+// sides have one, e.g. a re-upload of the same OVA changes its ETag and Last-Modified but not its digest. Otherwise the
+// headers are compared, values missing on either side are not compared.
+func drift(recorded SourceInfo, current SourceInfo) string {
+	if recorded.Digest != "" && current.Digest != "" {
+		if recorded.Digest != current.Digest {
+			return DriftDrifted
+		}
+		return DriftUpToDate
+	}
+	compared := false
+	for _, values := range [][2]string{
+		{recorded.ETag, current.ETag},
+		{recorded.LastModified, current.LastModified},
+	} {
+		if values[0] == "" || values[1] == "" {
+			continue
+		}
+		if values[0] != values[1] {
+			return DriftDrifted
+		}
+		compared = true
+	}
+	if !compared {
+		return DriftUnknown
+	}
+	return DriftUpToDate
+}
+
+func (o DeployOptions) onDrift() string {
+	if o.OnDrift == "" {
+		return OnDriftSkip
+	}
+	return o.OnDrift
+}
+
+// validateOnDrift checks the OnDrift option
+func (o DeployOptions) validateOnDrift() error {
+	switch o.onDrift() {
+	case OnDriftSkip, OnDriftFail, OnDriftReplace:
+		return nil
+	}
+	return errors.New(fmt.Sprintf("unknown drift policy %v, must be skip, fail or replace", o.OnDrift))
+}
+
+// source returns the identity of an OVA that is recorded on the template
+func (s *Session) source(ovaPath string) (SourceInfo, error) {
+	ovaClient, err := newOVA(s.Conn, ovaPath)
+	if err != nil {
+		return SourceInfo{}, errors.WithMessage(err, "unable to create ova client")
+	}
+	return ovaClient.getSource(ovaPath)
+}
+
+// checkDrift compares the source recorded on an existing template with the current source OVA
+func (s *Session) checkDrift(ctx context.Context, vm *object.VirtualMachine, ovaPath string) (string, SourceInfo, error) {
+	current, err := s.source(ovaPath)
+	if err != nil {
+		return DriftUnknown, current, errors.WithMessagef(err, "unable to read the source of %v", ovaPath)
+	}
+	props, err := getProperties(ctx, vm)
+	if err != nil {
+		return DriftUnknown, current, err
+	}
+	if props.Config == nil {
+		return DriftUnknown, current, nil
+	}
+	return drift(sourceFromMetadata(getMetadata(props.Config.Annotation)), current), current, nil
+}
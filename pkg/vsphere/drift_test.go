@@ -0,0 +1,161 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestDrift(t *testing.T) {
+	tests := map[string]struct {
+		recorded SourceInfo
+		current  SourceInfo
+		expected string
+	}{
+		"same digest":          {SourceInfo{Digest: "a"}, SourceInfo{Digest: "a"}, DriftUpToDate},
+		"other digest":         {SourceInfo{Digest: "a"}, SourceInfo{Digest: "b"}, DriftDrifted},
+		"re-uploaded":          {SourceInfo{Digest: "a", ETag: `"1"`, LastModified: "Mon"}, SourceInfo{Digest: "a", ETag: `"2"`, LastModified: "Tue"}, DriftUpToDate},
+		"other etag":           {SourceInfo{ETag: `"1"`}, SourceInfo{Digest: "a", ETag: `"2"`}, DriftDrifted},
+		"other last modified":  {SourceInfo{Digest: "a", LastModified: "Mon"}, SourceInfo{LastModified: "Tue"}, DriftDrifted},
+		"etag no longer sent":  {SourceInfo{Digest: "a", ETag: `"1"`}, SourceInfo{Digest: "a"}, DriftUpToDate},
+		"nothing recorded":     {SourceInfo{}, SourceInfo{Digest: "a", ETag: `"1"`}, DriftUnknown},
+		"only etag recorded":   {SourceInfo{ETag: `"1"`}, SourceInfo{Digest: "a", ETag: `"1"`}, DriftUpToDate},
+		"nothing to compare":   {SourceInfo{ETag: `"1"`}, SourceInfo{Digest: "a"}, DriftUnknown},
+		"same etag and digest": {SourceInfo{Digest: "a", ETag: `"1"`}, SourceInfo{Digest: "a", ETag: `"1"`}, DriftUpToDate},
+	}
+	for name, tc := range tests {
+		if actual := drift(tc.recorded, tc.current); actual != tc.expected {
+			t.Errorf("%v: expected: %v, actual: %v", name, tc.expected, actual)
+		}
+	}
+}
+
+func TestDeployOVATemplateDrift(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "drift-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13", Product: "Tiny", Version: "1"})
+	original, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if original.Source.Digest == "" {
+		t.Fatal("expected the source digest")
+	}
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.AlreadyExists || info.Drift != DriftUpToDate {
+		t.Fatalf("expected an up to date template, actual: %+v", info)
+	}
+
+	// the OVA is rebuilt under the same name
+	rebuilt := newTestOVA(t, testOVF{Name: "drift-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13", Product: "Tiny", Version: "2"})
+	if err := os.Rename(rebuilt, ovaPath); err != nil {
+		t.Fatal(err)
+	}
+	info, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.AlreadyExists || info.Drift != DriftDrifted {
+		t.Fatalf("expected a drifted template to be skipped, actual: %+v", info)
+	}
+
+	sim.conn.Options.OnDrift = OnDriftFail
+	if _, err := sim.conn.DeployOVATemplate(ovaPath); err == nil {
+		t.Fatal("expected an error for a drifted template")
+	}
+
+	sim.conn.Options.OnDrift = OnDriftReplace
+	info, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AlreadyExists || info.Replaced == nil || info.Replaced.Previous != original.VMObject.Reference().Value {
+		t.Fatalf("expected the drifted template to be replaced, actual: %+v", info)
+	}
+	info, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.AlreadyExists || info.Drift != DriftUpToDate {
+		t.Fatalf("expected the replacement to be up to date, actual: %+v", info)
+	}
+}
+
+func TestDeployOVATemplateDriftETag(t *testing.T) {
+	useTestTargets(t)
+	ova, err := ioutil.ReadFile(newTestOVA(t, testOVF{Name: "etag-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := `"1"`
+	expired := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if expired {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		_, _ = w.Write(ova)
+	}))
+	defer server.Close()
+	ovaURL := server.URL + "/images/etag-tiny.ova?X-Amz-Signature=abc"
+
+	info, err := sim.conn.DeployOVATemplate(ovaURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TemplateName != "etag-tiny" || info.Source.ETag != etag {
+		t.Fatalf("unexpected import %+v", info)
+	}
+
+	// a re-upload of the same OVA changes the ETag but not the digest
+	etag = `"2"`
+	info, err = sim.conn.DeployOVATemplate(ovaURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Drift != DriftUpToDate {
+		t.Fatalf("expected: %v, actual: %v", DriftUpToDate, info.Drift)
+	}
+
+	// the signed URL expired, the existing template is kept
+	expired = true
+	info, err = sim.conn.DeployOVATemplate(ovaURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.AlreadyExists || info.Drift != DriftUnknown {
+		t.Fatalf("expected an existing template with unknown drift, actual: %+v", info)
+	}
+	sim.conn.Options.OnDrift = OnDriftFail
+	if _, err := sim.conn.DeployOVATemplate(ovaURL); err == nil {
+		t.Fatal("expected an error when the drift can't be checked")
+	}
+}
+
+func TestDeployOVATemplateDriftUnknown(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Name = "DC0_H0_VM0"
+	sim.conn.Options.Mode = ModeVM
+	ovaPath := newTestOVA(t, testOVF{Name: "unknown-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.AlreadyExists || info.Drift != DriftUnknown {
+		t.Fatalf("expected a VM without a recorded source to be unknown, actual: %+v", info)
+	}
+
+	sim.conn.Options.OnDrift = "ignore"
+	if _, err := sim.conn.DeployOVATemplate(ovaPath); err == nil {
+		t.Fatal("expected an error for an unknown drift policy")
+	}
+}
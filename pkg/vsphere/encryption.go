@@ -0,0 +1,74 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// keyProviders returns the key providers (KMS clusters) configured on the vCenter
+func (s *Session) keyProviders(ctx context.Context) ([]types.KmipClusterInfo, error) {
+	if s.IsESXi() {
+		return nil, errors.New("VM encryption needs a vCenter, not a standalone ESXi host")
+	}
+	cm := s.Conn.ServiceContent.CryptoManager
+	if cm == nil {
+		return nil, errors.New("vCenter has no crypto manager, VM encryption is not supported")
+	}
+	res, err := methods.ListKmipServers(ctx, s.Conn.Client, &types.ListKmipServers{This: *cm})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list key providers")
+	}
+	return res.Returnval, nil
+}
+
+// keyProvider returns the ID of the KeyProvider option, or of the default key provider when it's empty.
+// It fails when no key provider is configured so that nothing is uploaded for an import that can't be encrypted.
+func (s *Session) keyProvider(ctx context.Context) (string, error) {
+	providers, err := s.keyProviders(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(providers) == 0 {
+		return "", errors.New("VM encryption needs a key provider but none is configured on the vCenter")
+	}
+	for _, p := range providers {
+		if p.ClusterId.Id == s.Options.KeyProvider || (s.Options.KeyProvider == "" && p.UseAsDefault) {
+			return p.ClusterId.Id, nil
+		}
+	}
+	if s.Options.KeyProvider == "" {
+		return "", errors.New("no default key provider is configured on the vCenter, a key provider must be given")
+	}
+	return "", errors.New(fmt.Sprintf("key provider %v is not configured on the vCenter", s.Options.KeyProvider))
+}
+
+// encryptionSpec generates a new key with a key provider and returns the crypto spec that encrypts a VM with it
+func (s *Session) encryptionSpec(ctx context.Context, provider string) (*types.CryptoSpecEncrypt, error) {
+	res, err := methods.GenerateKey(ctx, s.Conn.Client, &types.GenerateKey{
+		This:        *s.Conn.ServiceContent.CryptoManager,
+		KeyProvider: &types.KeyProviderId{Id: provider},
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to generate a key with key provider %v", provider)
+	}
+	if !res.Returnval.Success {
+		return nil, errors.New(fmt.Sprintf("unable to generate a key with key provider %v: %v", provider, res.Returnval.Reason))
+	}
+	return &types.CryptoSpecEncrypt{CryptoKeyId: res.Returnval.KeyId}, nil
+}
+
+// applyCryptoSpec encrypts the VM home and every disk of an import spec
+func applyCryptoSpec(spec *types.VirtualMachineImportSpec, crypto types.BaseCryptoSpec) {
+	spec.ConfigSpec.Crypto = crypto
+	for _, change := range spec.ConfigSpec.DeviceChange {
+		dc := change.GetVirtualDeviceConfigSpec()
+		if _, ok := dc.Device.(*types.VirtualDisk); ok {
+			dc.Backing = &types.VirtualDeviceConfigSpecBackingSpec{Crypto: crypto}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"strings"
+	"testing"
+
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestDeployOVATemplateEncryptWithoutKeyProvider(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Encrypt = true
+	ovaPath := newTestOVA(t, testOVF{Name: "encrypted-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	// the simulator has no key providers
+	_, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	errMsg := "unable to encrypt encrypted-tiny: unable to list key providers"
+	if !strings.HasPrefix(err.Error(), errMsg) {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err.Error())
+	}
+	if _, err := sim.conn.GetVM("encrypted-tiny"); err == nil {
+		t.Fatal("expected nothing to be imported")
+	}
+}
+
+func TestDeployOVATemplateEncryptWithUnencryptedPolicy(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Encrypt = true
+	sim.conn.Options.StoragePolicy = "vSAN Default Storage Policy"
+	ovaPath := newTestOVA(t, testOVF{Name: "policy-encrypted-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	errMsg := "unable to encrypt policy-encrypted-tiny: storage policy vSAN Default Storage Policy has no encryption"
+	if !strings.HasPrefix(err.Error(), errMsg) {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err.Error())
+	}
+	if info.KeyProvider != "" {
+		t.Fatalf("expected no key provider, actual: %v", info.KeyProvider)
+	}
+	if _, err := sim.conn.GetVM("policy-encrypted-tiny"); err == nil {
+		t.Fatal("expected nothing to be imported")
+	}
+}
+
+func TestEncryptionCapability(t *testing.T) {
+	profile := func(id string, lineOfService string, namespaces ...string) pbmtypes.BasePbmProfile {
+		var capabilities []pbmtypes.PbmCapabilityInstance
+		for _, ns := range namespaces {
+			capabilities = append(capabilities, pbmtypes.PbmCapabilityInstance{Id: pbmtypes.PbmCapabilityMetadataUniqueId{Namespace: ns, Id: "encryption-service"}})
+		}
+		return &pbmtypes.PbmCapabilityProfile{
+			PbmProfile:    pbmtypes.PbmProfile{ProfileId: pbmtypes.PbmProfileId{UniqueId: id}},
+			LineOfService: lineOfService,
+			Constraints:   &pbmtypes.PbmCapabilitySubProfileConstraints{SubProfiles: []pbmtypes.PbmCapabilitySubProfile{{Capability: capabilities}}},
+		}
+	}
+	tests := map[string]struct {
+		profile  pbmtypes.BasePbmProfile
+		services []pbmtypes.BasePbmProfile
+		expected bool
+	}{
+		"io filter":            {profile("p", "", "VSAN", encryptionNamespace), nil, true},
+		"data service":         {profile("p", "", dataServiceNamespace), []pbmtypes.BasePbmProfile{profile("encryption-service", "ENCRYPTION")}, true},
+		"other data service":   {profile("p", "", dataServiceNamespace), []pbmtypes.BasePbmProfile{profile("encryption-service", "REPLICATION")}, false},
+		"missing data service": {profile("p", "", dataServiceNamespace), nil, false},
+		"no encryption":        {profile("p", "", "VSAN"), nil, false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			retrieve := func(ids []string) ([]pbmtypes.BasePbmProfile, error) {
+				if len(ids) != 1 || ids[0] != "encryption-service" {
+					t.Fatalf("unexpected data service policies %v", ids)
+				}
+				return tc.services, nil
+			}
+			encrypts, err := encryptionCapability([]pbmtypes.BasePbmProfile{tc.profile}, retrieve)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if encrypts != tc.expected {
+				t.Fatalf("expected: %v, actual: %v", tc.expected, encrypts)
+			}
+		})
+	}
+}
+
+func TestApplyCryptoSpec(t *testing.T) {
+	spec := &types.VirtualMachineImportSpec{}
+	spec.ConfigSpec.DeviceChange = []types.BaseVirtualDeviceConfigSpec{
+		&types.VirtualDeviceConfigSpec{Device: &types.VirtualDisk{}},
+		&types.VirtualDeviceConfigSpec{Device: &types.VirtualVmxnet3{}},
+	}
+	crypto := &types.CryptoSpecEncrypt{CryptoKeyId: types.CryptoKeyId{KeyId: "key", ProviderId: &types.KeyProviderId{Id: "kms"}}}
+	applyCryptoSpec(spec, crypto)
+
+	if spec.ConfigSpec.Crypto != crypto {
+		t.Fatalf("expected the VM home to be encrypted, actual: %v", spec.ConfigSpec.Crypto)
+	}
+	disk := spec.ConfigSpec.DeviceChange[0].GetVirtualDeviceConfigSpec()
+	if disk.Backing == nil || disk.Backing.Crypto != crypto {
+		t.Fatalf("expected the disk to be encrypted, actual: %v", disk.Backing)
+	}
+	if nic := spec.ConfigSpec.DeviceChange[1].GetVirtualDeviceConfigSpec(); nic.Backing != nil {
+		t.Fatalf("expected the NIC to have no crypto spec, actual: %v", nic.Backing)
+	}
+}
@@ -0,0 +1,232 @@
+package vsphere
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/nfc"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ExportFile is a file written to an exported OVA
+type ExportFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// ExportResult describes an OVA written by Export, the files are in the order of the archive
+type ExportResult struct {
+	Name  string       `json:"name"`
+	Files []ExportFile `json:"files"`
+}
+
+// Export writes a VM or template as an OVA: the OVF descriptor created by vSphere comes first, followed by a SHA256
+// manifest and the disks of an export lease. The disks are spooled to temporary files while they're downloaded, the
+// manifest needs their hashes before they're written, so the temporary directory needs room for all of them.
+func (s *Session) Export(vm *object.VirtualMachine, w io.Writer) (ExportResult, error) {
+	ctx := s.Ctx
+	name := path.Base(vm.InventoryPath)
+	if name == "." || name == "/" {
+		var err error
+		if name, err = vm.ObjectName(ctx); err != nil {
+			return ExportResult{}, errors.Wrapf(err, "unable to get the name of %v", vm.Reference())
+		}
+	}
+
+	lease, err := vm.Export(ctx)
+	if err != nil {
+		return ExportResult{Name: name, Files: []ExportFile{}}, errors.Wrapf(err, "unable to export %v", name)
+	}
+	info, err := lease.Wait(ctx, nil)
+	if err != nil {
+		return ExportResult{Name: name, Files: []ExportFile{}}, errors.Wrapf(err, "unable to export %v", name)
+	}
+	u := lease.StartUpdater(ctx, info)
+	defer u.Done()
+
+	var disks []nfc.FileItem
+	for _, item := range info.Items {
+		if path.Ext(item.Path) != ".vmdk" {
+			continue
+		}
+		item.Path = exportDiskName(name, item.Path)
+		disks = append(disks, item)
+	}
+	download := func(ctx context.Context, item nfc.FileItem) (io.ReadCloser, int64, error) {
+		r, size, err := s.Conn.Client.Download(ctx, item.URL, &soap.Download{Progress: item})
+		return r, size, errors.Wrapf(err, "unable to download %v", item.Path)
+	}
+	describe := func(ctx context.Context, files []types.OvfFile) (string, error) {
+		desc, err := ovf.NewManager(s.Conn.Client).CreateDescriptor(ctx, vm, types.OvfCreateDescriptorParams{Name: name, OvfFiles: files})
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to create the OVF descriptor of %v", name)
+		}
+		if desc.Error != nil {
+			return "", errors.New(fmt.Sprintf("unable to create the OVF descriptor of %v, %v", name, desc.Error[0].LocalizedMessage))
+		}
+		return desc.OvfDescriptor, nil
+	}
+	result, err := writeOVA(ctx, w, name, disks, download, describe)
+	if err != nil {
+		_ = lease.Abort(ctx, nil)
+		return result, errors.WithMessagef(err, "unable to export %v", name)
+	}
+	if err := lease.Complete(ctx); err != nil {
+		return result, errors.Wrapf(err, "unable to complete the export of %v", name)
+	}
+	return result, nil
+}
+
+// writeOVA downloads and spools the disks, then writes <name>.ovf, as returned by describe for the disks,
+// <name>.mf and the disks to w
+func writeOVA(ctx context.Context, w io.Writer, name string, disks []nfc.FileItem, download func(context.Context, nfc.FileItem) (io.ReadCloser, int64, error), describe func(context.Context, []types.OvfFile) (string, error)) (ExportResult, error) {
+	result := ExportResult{Name: name, Files: []ExportFile{}}
+	var spooled []*spooledFile
+	defer func() {
+		for _, f := range spooled {
+			_ = f.Close()
+		}
+	}()
+	var diskFiles []ExportFile
+	var ovfFiles []types.OvfFile
+	for _, item := range disks {
+		file, f, err := spoolDisk(ctx, item, download)
+		if err != nil {
+			return result, err
+		}
+		spooled = append(spooled, f)
+		diskFiles = append(diskFiles, file)
+		item.Size = file.Size
+		ovfFiles = append(ovfFiles, item.File())
+	}
+	descriptor, err := describe(ctx, ovfFiles)
+	if err != nil {
+		return result, err
+	}
+
+	archive := newOVAWriter(w)
+	ovfFile, err := archive.add(name+".ovf", strings.NewReader(descriptor), int64(len(descriptor)))
+	if err != nil {
+		return result, err
+	}
+	if err := archive.manifest(name+".mf", append([]ExportFile{ovfFile}, diskFiles...)); err != nil {
+		return result, err
+	}
+	for i, f := range spooled {
+		if _, err := archive.add(diskFiles[i].Name, f, f.size); err != nil {
+			return result, err
+		}
+	}
+	if err := archive.close(); err != nil {
+		return result, err
+	}
+	result.Files = archive.files
+	return result, nil
+}
+
+// spoolDisk downloads a disk of an export lease to a temporary file and hashes it
+func spoolDisk(ctx context.Context, item nfc.FileItem, download func(context.Context, nfc.FileItem) (io.ReadCloser, int64, error)) (ExportFile, *spooledFile, error) {
+	r, _, err := download(ctx, item)
+	if err != nil {
+		return ExportFile{}, nil, err
+	}
+	defer r.Close()
+	h := sha256.New()
+	f, err := spool(io.TeeReader(r, h))
+	if err != nil {
+		return ExportFile{}, nil, errors.WithMessagef(err, "unable to download %v", item.Path)
+	}
+	return ExportFile{Name: item.Path, Size: f.size, Sha256: hex.EncodeToString(h.Sum(nil))}, f, nil
+}
+
+// exportDiskName prefixes the disk files of the lease, named after the device, with the name of the VM
+func exportDiskName(name string, disk string) string {
+	if strings.HasPrefix(disk, name) {
+		return disk
+	}
+	return name + "-" + disk
+}
+
+// ovaWriter writes the files of an OVA to a tar archive and keeps their SHA256 for the manifest
+type ovaWriter struct {
+	tw    *tar.Writer
+	files []ExportFile
+}
+
+func newOVAWriter(w io.Writer) *ovaWriter {
+	return &ovaWriter{tw: tar.NewWriter(w)}
+}
+
+// add writes a file of size bytes to the archive
+func (o *ovaWriter) add(name string, r io.Reader, size int64) (ExportFile, error) {
+	header := &tar.Header{Name: name, Size: size, Mode: 0644, ModTime: time.Now(), Format: tar.FormatPAX}
+	if err := o.tw.WriteHeader(header); err != nil {
+		return ExportFile{}, errors.Wrapf(err, "unable to write %v to the OVA", name)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(o.tw, h), r); err != nil {
+		return ExportFile{}, errors.Wrapf(err, "unable to write %v to the OVA", name)
+	}
+	file := ExportFile{Name: name, Size: size, Sha256: hex.EncodeToString(h.Sum(nil))}
+	o.files = append(o.files, file)
+	return file, nil
+}
+
+// manifest writes a SHA256 manifest of files to the archive
+func (o *ovaWriter) manifest(name string, files []ExportFile) error {
+	var mf strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&mf, "SHA256(%s)= %s\n", file.Name, file.Sha256)
+	}
+	_, err := o.add(name, strings.NewReader(mf.String()), int64(mf.Len()))
+	return err
+}
+
+// close closes the archive
+func (o *ovaWriter) close() error {
+	return errors.Wrap(o.tw.Close(), "unable to close the OVA")
+}
+
+// spooledFile is a temporary file that is removed when it's closed
+type spooledFile struct {
+	*os.File
+	size int64
+}
+
+func (f *spooledFile) Close() error {
+	_ = f.File.Close()
+	return os.Remove(f.Name())
+}
+
+// spool copies a reader to a temporary file
+func spool(r io.Reader) (*spooledFile, error) {
+	f, err := ioutil.TempFile("", "ovaimporter-export-")
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create a temporary file")
+	}
+	spooled := &spooledFile{File: f}
+	if spooled.size, err = io.Copy(f, r); err != nil {
+		_ = spooled.Close()
+		return nil, errors.Wrap(err, "unable to write a temporary file")
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = spooled.Close()
+		return nil, errors.Wrap(err, "unable to read a temporary file")
+	}
+	return spooled, nil
+}
@@ -0,0 +1,150 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/nfc"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// TestWriteOVA fakes the download and the descriptor of an export lease, the simulator has no ExportVm
+func TestWriteOVA(t *testing.T) {
+	useTestTargets(t)
+	var descriptor bytes.Buffer
+	if err := testOVFTemplate.Execute(&descriptor, testOVF{Name: "export-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"}); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "ovaimporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	ovaPath := filepath.Join(dir, "export-tiny.ova")
+	f, err := os.Create(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	disk := make([]byte, 512)
+	// the host doesn't send the size of the disk
+	download := func(ctx context.Context, item nfc.FileItem) (io.ReadCloser, int64, error) {
+		if item.Path != "disk-0.vmdk" {
+			return nil, 0, fmt.Errorf("unexpected disk %v", item.Path)
+		}
+		return ioutil.NopCloser(bytes.NewReader(disk)), -1, nil
+	}
+	var described []types.OvfFile
+	describe := func(ctx context.Context, files []types.OvfFile) (string, error) {
+		described = files
+		return descriptor.String(), nil
+	}
+	disks := []nfc.FileItem{{OvfFileItem: types.OvfFileItem{DeviceId: "/vm-1/VirtualLsiLogicController0:0", Path: "disk-0.vmdk"}}}
+	result, err := writeOVA(context.Background(), f, "export-tiny", disks, download, describe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(described) != 1 || described[0].Path != "disk-0.vmdk" || described[0].Size != 512 {
+		t.Fatalf("expected the descriptor to be created for the downloaded disk, actual: %+v", described)
+	}
+	var names []string
+	for _, file := range result.Files {
+		names = append(names, file.Name)
+	}
+	if fmt.Sprint(names) != "[export-tiny.ovf export-tiny.mf disk-0.vmdk]" {
+		t.Fatalf("expected the descriptor and manifest before the disk, actual: %v", names)
+	}
+	diskSum := sha256.Sum256(disk)
+	if result.Files[2].Size != 512 || result.Files[2].Sha256 != hex.EncodeToString(diskSum[:]) {
+		t.Fatalf("unexpected disk %+v", result.Files[2])
+	}
+	h := &handler{}
+	manifest, err := h.readOvf("*.mf", ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := fmt.Sprintf("SHA256(export-tiny.ovf)= %v\nSHA256(disk-0.vmdk)= %x\n", result.Files[0].Sha256, diskSum)
+	if string(manifest) != expected {
+		t.Fatalf("expected manifest %q, actual: %q", expected, manifest)
+	}
+
+	// the digest covers the descriptor and manifest
+	source, err := h.getSource(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty := sha256.Sum256(nil)
+	if source.Digest == "" || source.Digest == hex.EncodeToString(empty[:]) {
+		t.Fatalf("expected a digest of the descriptor and manifest, actual: %v", source.Digest)
+	}
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.TemplateName != "export-tiny" {
+		t.Fatalf("expected: export-tiny, actual: %v", info.TemplateName)
+	}
+}
+
+func TestWriteOVADownloadFailure(t *testing.T) {
+	download := func(ctx context.Context, item nfc.FileItem) (io.ReadCloser, int64, error) {
+		return nil, 0, fmt.Errorf("lease expired")
+	}
+	describe := func(ctx context.Context, files []types.OvfFile) (string, error) {
+		t.Fatal("the descriptor is created after the download")
+		return "", nil
+	}
+	var ova bytes.Buffer
+	disks := []nfc.FileItem{{OvfFileItem: types.OvfFileItem{Path: "disk-0.vmdk"}}}
+	if _, err := writeOVA(context.Background(), &ova, "export-tiny", disks, download, describe); err == nil {
+		t.Fatal("expected the download to fail")
+	}
+	if ova.Len() != 0 {
+		t.Fatalf("expected nothing to be written, actual: %d bytes", ova.Len())
+	}
+}
+
+func TestSpool(t *testing.T) {
+	spooled, err := spool(strings.NewReader("disk"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(spooled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "disk" || spooled.size != 4 {
+		t.Fatalf("unexpected spooled file %q of size %d", b, spooled.size)
+	}
+	if err := spooled.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(spooled.Name()); !os.IsNotExist(err) {
+		t.Fatal("expected the spooled file to be removed")
+	}
+}
+
+func TestExportDiskName(t *testing.T) {
+	tests := map[string]string{
+		"disk-0.vmdk":       "tpl-disk-0.vmdk",
+		"tpl-disk-1.vmdk":   "tpl-disk-1.vmdk",
+		"other-disk-0.vmdk": "tpl-other-disk-0.vmdk",
+	}
+	for disk, expected := range tests {
+		if actual := exportDiskName("tpl", disk); actual != expected {
+			t.Errorf("%v: expected: %v, actual: %v", disk, expected, actual)
+		}
+	}
+}
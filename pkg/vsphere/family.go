@@ -0,0 +1,54 @@
+package vsphere
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+)
+
+// Tag categories of the family and version of imported templates, they are created on first use
+const (
+	FamilyTagCategory  = "ovaimporter-family"
+	VersionTagCategory = "ovaimporter-version"
+)
+
+// Metadata keys of the family and version stored in the annotation of an imported template
+const (
+	MetadataFamily  = "family"
+	MetadataVersion = "version"
+)
+
+// validateFamily checks the Family option before anything is uploaded. The vAPI session the tags are attached with is
+// logged in here, a login that fails doesn't cost an upload.
+func (s *Session) validateFamily() error {
+	if s.Options.Family == "" {
+		return nil
+	}
+	if s.IsESXi() {
+		return errors.New("families are tags, they need a vCenter, not a standalone ESXi host")
+	}
+	_, err := s.RestClient()
+	return errors.WithMessagef(err, "unable to tag family %v", s.Options.Family)
+}
+
+// familyVersion returns the Version option, or the product version of the OVF, or the start of the source digest
+func (o DeployOptions) familyVersion(env *ovf.Envelope, source SourceInfo) string {
+	if o.Version != "" {
+		return o.Version
+	}
+	if product := ovfProduct(env); product != nil && product.Version != "" {
+		return product.Version
+	}
+	return source.Digest[:shortDigestLength]
+}
+
+// tagFamily tags a template with its family and version
+func (s *Session) tagFamily(ctx context.Context, vm *object.VirtualMachine, family string, version string) error {
+	if err := s.attachTag(ctx, vm, FamilyTagCategory, family); err != nil {
+		return err
+	}
+	return s.attachTag(ctx, vm, VersionTagCategory, version)
+}
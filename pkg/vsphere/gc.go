@@ -0,0 +1,231 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// GCOptions selects the templates of a family that GarbageCollect removes
+type GCOptions struct {
+	Family string
+	// Keep is the number of newest templates of the family that are kept
+	Keep int
+	// ProtectTag is a category/tag, templates that have it are never removed
+	ProtectTag string
+	// DryRun reports what would be removed without removing it
+	DryRun bool
+}
+
+// GCTemplate is a template of a family considered by GarbageCollect
+type GCTemplate struct {
+	Name       string `json:"name"`
+	ID         string `json:"id"`
+	Version    string `json:"version,omitempty"`
+	ImportedAt string `json:"importedAt,omitempty"`
+	// Reason the template was kept or skipped
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// GCResult is the report of GarbageCollect, in a dry run Removed is what would be removed
+type GCResult struct {
+	Family  string       `json:"family"`
+	Keep    int          `json:"keep"`
+	DryRun  bool         `json:"dryRun"`
+	Kept    []GCTemplate `json:"kept"`
+	Removed []GCTemplate `json:"removed"`
+	Skipped []GCTemplate `json:"skipped"`
+}
+
+// GarbageCollect keeps the newest templates of a family, ordered by import time, and removes the older ones.
+// Older templates that are in use, because they have linked clones or the protect tag, are skipped, and so are members
+// of the family that are powered on or aren't templates.
+func (s *Session) GarbageCollect(opts GCOptions) (GCResult, error) {
+	result := GCResult{Family: opts.Family, Keep: opts.Keep, DryRun: opts.DryRun, Kept: []GCTemplate{}, Removed: []GCTemplate{}, Skipped: []GCTemplate{}}
+	ctx := s.Ctx
+	if opts.Family == "" {
+		return result, errors.New("a family must be given")
+	}
+	if opts.Keep < 0 {
+		return result, errors.New(fmt.Sprintf("can't keep %d templates", opts.Keep))
+	}
+
+	members, err := s.familyMembers(ctx, opts.Family)
+	if err != nil {
+		return result, err
+	}
+	protected := map[types.ManagedObjectReference]bool{}
+	if opts.ProtectTag != "" {
+		protected, err = s.taggedObjects(opts.ProtectTag)
+		if err != nil {
+			return result, err
+		}
+	}
+	clones, err := s.linkedClones(ctx, members)
+	if err != nil {
+		return result, err
+	}
+
+	failed := 0
+	for i, member := range members {
+		metadata := map[string]string{}
+		if member.Config != nil {
+			metadata = getMetadata(member.Config.Annotation)
+		}
+		template := GCTemplate{
+			Name:       member.Name,
+			ID:         member.Self.Value,
+			Version:    metadata[MetadataVersion],
+			ImportedAt: metadata[MetadataImportedAt],
+		}
+		switch {
+		case i < opts.Keep:
+			template.Reason = fmt.Sprintf("one of the %d newest", opts.Keep)
+			result.Kept = append(result.Kept, template)
+			continue
+		case protected[member.Self]:
+			template.Reason = fmt.Sprintf("tagged with %v", opts.ProtectTag)
+			result.Skipped = append(result.Skipped, template)
+			continue
+		case len(clones[member.Self]) > 0:
+			template.Reason = fmt.Sprintf("has linked clones: %v", strings.Join(clones[member.Self], ", "))
+			result.Skipped = append(result.Skipped, template)
+			continue
+		case member.Runtime.PowerState == types.VirtualMachinePowerStatePoweredOn:
+			template.Reason = "powered on"
+			result.Skipped = append(result.Skipped, template)
+			continue
+		case member.Config == nil || !member.Config.Template:
+			// imported with --mode vm or vm-poweron, the VM may be in use
+			template.Reason = "not a template"
+			result.Skipped = append(result.Skipped, template)
+			continue
+		}
+		if !opts.DryRun {
+			if err := destroyVM(ctx, object.NewVirtualMachine(s.Conn.Client, member.Self)); err != nil {
+				template.Error = err.Error()
+				failed++
+			}
+		}
+		result.Removed = append(result.Removed, template)
+	}
+	if failed > 0 {
+		return result, errors.New(fmt.Sprintf("unable to remove %d templates of family %v", failed, opts.Family))
+	}
+	return result, nil
+}
+
+// familyMembers returns the VMs tagged with a family, newest import first
+func (s *Session) familyMembers(ctx context.Context, family string) ([]mo.VirtualMachine, error) {
+	tagged, err := s.taggedObjects(FamilyTagCategory + "/" + family)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to find family %v", family)
+	}
+	var refs []types.ManagedObjectReference
+	for ref := range tagged {
+		if ref.Type == "VirtualMachine" {
+			refs = append(refs, ref)
+		}
+	}
+	var members []mo.VirtualMachine
+	if len(refs) == 0 {
+		return members, nil
+	}
+	pc := property.DefaultCollector(s.Conn.Client)
+	if err := pc.Retrieve(ctx, refs, []string{"name", "config.annotation", "config.template", "config.hardware.device", "runtime.powerState"}, &members); err != nil {
+		return nil, errors.Wrapf(err, "unable to get the templates of family %v", family)
+	}
+	importedAt := func(vm mo.VirtualMachine) string {
+		if vm.Config == nil {
+			return ""
+		}
+		return getMetadata(vm.Config.Annotation)[MetadataImportedAt]
+	}
+	// RFC3339 UTC timestamps sort as strings, templates without one are the oldest
+	sort.Slice(members, func(i, j int) bool {
+		if a, b := importedAt(members[i]), importedAt(members[j]); a != b {
+			return a > b
+		}
+		return members[i].Name > members[j].Name
+	})
+	return members, nil
+}
+
+// linkedClones returns the names of the VMs in the datacenter whose disks have a disk of one of the templates as parent
+func (s *Session) linkedClones(ctx context.Context, templates []mo.VirtualMachine) (map[types.ManagedObjectReference][]string, error) {
+	clones := make(map[types.ManagedObjectReference][]string)
+	owners := make(map[string]types.ManagedObjectReference)
+	for _, template := range templates {
+		if template.Config == nil {
+			continue
+		}
+		for _, file := range diskFiles(template.Config.Hardware.Device, false) {
+			owners[file] = template.Self
+		}
+	}
+	if len(owners) == 0 {
+		return clones, nil
+	}
+
+	v, err := view.NewManager(s.Conn.Client).CreateContainerView(ctx, s.Datacenter.Reference(), []string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create a view of the virtual machines")
+	}
+	defer func() { _ = v.Destroy(ctx) }()
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name", "config.hardware.device"}, &vms); err != nil {
+		return nil, errors.Wrap(err, "unable to get the disks of the virtual machines")
+	}
+	for _, vm := range vms {
+		if vm.Config == nil {
+			continue
+		}
+		seen := make(map[types.ManagedObjectReference]bool)
+		for _, file := range diskFiles(vm.Config.Hardware.Device, true) {
+			owner, ok := owners[file]
+			if !ok || owner == vm.Self || seen[owner] {
+				continue
+			}
+			seen[owner] = true
+			clones[owner] = append(clones[owner], vm.Name)
+		}
+	}
+	return clones, nil
+}
+
+// diskFiles returns the files of the disks in devices and their parent disks, or only the parents
+func diskFiles(devices []types.BaseVirtualDevice, parentsOnly bool) []string {
+	var files []string
+	for _, device := range object.VirtualDeviceList(devices).SelectByType((*types.VirtualDisk)(nil)) {
+		var chain []string
+		switch backing := device.(*types.VirtualDisk).Backing.(type) {
+		case *types.VirtualDiskFlatVer2BackingInfo:
+			for b := backing; b != nil; b = b.Parent {
+				chain = append(chain, b.FileName)
+			}
+		case *types.VirtualDiskSeSparseBackingInfo:
+			for b := backing; b != nil; b = b.Parent {
+				chain = append(chain, b.FileName)
+			}
+		case *types.VirtualDiskSparseVer2BackingInfo:
+			for b := backing; b != nil; b = b.Parent {
+				chain = append(chain, b.FileName)
+			}
+		}
+		if parentsOnly && len(chain) > 0 {
+			chain = chain[1:]
+		}
+		files = append(files, chain...)
+	}
+	return files
+}
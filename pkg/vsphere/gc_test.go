@@ -0,0 +1,168 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// makeLinkedClone clones a template and makes the disk of the clone a child of the template's disk,
+// the simulator doesn't create linked clones itself
+func makeLinkedClone(t *testing.T, template *object.VirtualMachine, name string) {
+	t.Helper()
+	ctx := context.Background()
+	devices, err := template.Device(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := devices.SelectByType((*types.VirtualDisk)(nil))[0].(*types.VirtualDisk).Backing.(*types.VirtualDiskFlatVer2BackingInfo)
+
+	pool := sim.conn.ResourcePool.Reference()
+	task, err := template.Clone(ctx, sim.conn.Folder, name, types.VirtualMachineCloneSpec{Location: types.VirtualMachineRelocateSpec{Pool: &pool}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clone := simulator.Map.Get(info.Result.(types.ManagedObjectReference)).(*simulator.VirtualMachine)
+	for _, device := range clone.Config.Hardware.Device {
+		if disk, ok := device.(*types.VirtualDisk); ok {
+			disk.Backing.(*types.VirtualDiskFlatVer2BackingInfo).Parent = parent
+		}
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Family = "gc-family"
+	templates := make(map[string]*object.VirtualMachine)
+	for i := 1; i <= 4; i++ {
+		name := fmt.Sprintf("gc-tiny-%d", i)
+		sim.conn.Options.Version = fmt.Sprintf("1.0.%d", i)
+		info, err := sim.conn.DeployOVATemplate(newTestOVA(t, testOVF{Name: name, OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Family != "gc-family" || info.Version != sim.conn.Options.Version {
+			t.Fatalf("unexpected family %v and version %v", info.Family, info.Version)
+		}
+		templates[name] = info.VMObject
+	}
+	tagged, err := sim.conn.taggedObjects(VersionTagCategory + "/1.0.4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tagged[templates["gc-tiny-4"].Reference()] {
+		t.Fatal("expected gc-tiny-4 to be tagged with its version")
+	}
+
+	// VMs of the family, the oldest imports, are in use
+	sim.conn.Options.Mode = ModeVMPowerOn
+	sim.conn.Options.Version = "0.9.0"
+	if _, err := sim.conn.DeployOVATemplate(newTestOVA(t, testOVF{Name: "gc-running", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})); err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Options.Mode = ModeVM
+	if _, err := sim.conn.DeployOVATemplate(newTestOVA(t, testOVF{Name: "gc-stopped", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})); err != nil {
+		t.Fatal(err)
+	}
+
+	makeLinkedClone(t, templates["gc-tiny-1"], "gc-clone")
+	if err := sim.conn.attachTag(context.Background(), templates["gc-tiny-2"], "gc-protect", "keep"); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := GCOptions{Family: "gc-family", Keep: 1, ProtectTag: "gc-protect/keep", DryRun: true}
+	result, err := sim.conn.GarbageCollect(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(templates []GCTemplate) string {
+		var s []string
+		for _, tpl := range templates {
+			s = append(s, tpl.Name)
+		}
+		return fmt.Sprint(s)
+	}
+	if names(result.Kept) != "[gc-tiny-4]" || names(result.Removed) != "[gc-tiny-3]" || names(result.Skipped) != "[gc-tiny-2 gc-tiny-1 gc-stopped gc-running]" {
+		t.Fatalf("unexpected report kept: %v, removed: %v, skipped: %v", names(result.Kept), names(result.Removed), names(result.Skipped))
+	}
+	if result.Removed[0].Version != "1.0.3" || result.Removed[0].ImportedAt == "" {
+		t.Fatalf("expected the version and import time of gc-tiny-3, actual: %+v", result.Removed[0])
+	}
+	if _, err := sim.conn.GetVM("gc-tiny-3"); err != nil {
+		t.Fatal("expected a dry run to keep gc-tiny-3")
+	}
+
+	opts.DryRun = false
+	result, err = sim.conn.GarbageCollect(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names(result.Removed) != "[gc-tiny-3]" {
+		t.Fatalf("expected: [gc-tiny-3], actual: %v", names(result.Removed))
+	}
+	if _, err := sim.conn.GetVM("gc-tiny-3"); err == nil {
+		t.Fatal("expected gc-tiny-3 to be removed")
+	}
+	if result.Skipped[2].Reason != "not a template" || result.Skipped[3].Reason != "powered on" {
+		t.Fatalf("expected the VMs of the family to be skipped, actual: %+v", result.Skipped)
+	}
+	for _, name := range []string{"gc-tiny-1", "gc-tiny-2", "gc-tiny-4", "gc-clone", "gc-running", "gc-stopped"} {
+		if _, err := sim.conn.GetVM(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGarbageCollectErrors(t *testing.T) {
+	tests := map[string]GCOptions{
+		"no family":      {Keep: 1},
+		"negative keep":  {Family: "gc-family", Keep: -1},
+		"unknown family": {Family: "no-such-family", Keep: 1},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := sim.conn.GarbageCollect(opts); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestValidateFamily(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := map[string]struct {
+		ctx    context.Context
+		family string
+		err    bool
+		login  bool
+	}{
+		"no family":     {context.Background(), "", false, false},
+		"family":        {context.Background(), "validate-family", false, true},
+		"login failure": {canceled, "validate-family", true, false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// a session of its own, the shared one is already logged in to the vAPI endpoint
+			s := &Session{Conn: sim.conn.Conn, Datacenter: sim.conn.Datacenter, Ctx: tc.ctx, user: sim.conn.user}
+			s.Options.Family = tc.family
+			err := s.validateFamily()
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %v, actual: %v", tc.err, err)
+			}
+			if (s.restCon != nil) != tc.login {
+				t.Fatalf("expected the vAPI session to be logged in: %v", tc.login)
+			}
+		})
+	}
+}
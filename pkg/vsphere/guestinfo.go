@@ -0,0 +1,40 @@
+package vsphere
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GuestInfo keys read by the cloud-init VMware datasource
+const (
+	GuestInfoUserData = "userdata"
+	GuestInfoMetaData = "metadata"
+)
+
+// guestInfoSizeLimit is the default tools.setInfo.sizeLimit, the largest guestinfo value the guest can read
+const guestInfoSizeLimit = 1 << 20
+
+var guestInfoKey = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// guestInfoExtraConfig returns the extraConfig that stores values as base64 encoded guestinfo.<key> (the prefix is optional in the keys),
+// each with a guestinfo.<key>.encoding=base64 entry as expected by cloud-init
+func guestInfoExtraConfig(values map[string]string) (map[string]string, error) {
+	extraConfig := make(map[string]string, 2*len(values))
+	for _, k := range sortedKeys(values) {
+		key := strings.TrimPrefix(k, "guestinfo.")
+		if !guestInfoKey.MatchString(key) {
+			return nil, errors.New(fmt.Sprintf("invalid guestinfo key %q", k))
+		}
+		encoded := base64.StdEncoding.EncodeToString([]byte(values[k]))
+		if len(encoded) > guestInfoSizeLimit {
+			return nil, errors.New(fmt.Sprintf("guestinfo.%v is %d bytes base64 encoded, more than the limit of %d bytes", key, len(encoded), guestInfoSizeLimit))
+		}
+		extraConfig["guestinfo."+key] = encoded
+		extraConfig["guestinfo."+key+".encoding"] = "base64"
+	}
+	return extraConfig, nil
+}
@@ -0,0 +1,60 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestDeployOVATemplateGuestInfo(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.GuestInfo = map[string]string{GuestInfoUserData: "#cloud-config\n", "guestinfo.role": "node"}
+	ovaPath := newTestOVA(t, testOVF{Name: "guestinfo-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, err := getProperties(sim.conn.Ctx, info.VMObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extraConfig := make(map[string]string)
+	for _, opt := range props.Config.ExtraConfig {
+		extraConfig[opt.GetOptionValue().Key], _ = opt.GetOptionValue().Value.(string)
+	}
+	expected := map[string]string{
+		"guestinfo.userdata":          base64.StdEncoding.EncodeToString([]byte("#cloud-config\n")),
+		"guestinfo.userdata.encoding": "base64",
+		"guestinfo.role":              base64.StdEncoding.EncodeToString([]byte("node")),
+		"guestinfo.role.encoding":     "base64",
+	}
+	for k, v := range expected {
+		if extraConfig[k] != v {
+			t.Fatalf("expected %v: %v, actual: %v", k, v, extraConfig[k])
+		}
+	}
+}
+
+func TestGuestInfoExtraConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		values map[string]string
+		errMsg string
+	}{
+		"invalid key": {map[string]string{"user data": "x"}, `invalid guestinfo key "user data"`},
+		"too large":   {map[string]string{"userdata": strings.Repeat("x", guestInfoSizeLimit)}, "guestinfo.userdata is 1398104 bytes base64 encoded, more than the limit of 1048576 bytes"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := guestInfoExtraConfig(tc.values)
+			if err == nil {
+				t.Fatal("received an unexpected nil error")
+			}
+			if err.Error() != tc.errMsg {
+				t.Fatalf("expected: %v, actual: %v", tc.errMsg, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25/soap"
+)
+
+// Library item states
+const (
+	LibraryItemCreated = "created"
+	LibraryItemExists  = "exists"
+	LibraryItemUpdated = "updated"
+)
+
+// libraryItemPath is the vAPI resource of content library items
+const libraryItemPath = "/com/vmware/content/library/item"
+
+// libraryUpdatePollInterval is how often an update session is checked while the library processes the upload
+const libraryUpdatePollInterval = time.Second
+
+// LibraryItem is the content library item an OVA was uploaded to
+type LibraryItem struct {
+	Library string `json:"library"`
+	Name    string `json:"name"`
+	ID      string `json:"id"`
+	// Version is the content version of the item, it changes with every upload
+	Version string `json:"version"`
+	// Status is created, exists (the item has the files of the same OVA) or updated
+	Status string `json:"status"`
+}
+
+// findLibrary returns the content library of the ContentLibrary option, which must be a local library
+func (s *Session) findLibrary(ctx context.Context) (*library.Library, error) {
+	c, err := s.RestClient()
+	if err != nil {
+		return nil, err
+	}
+	lib, err := library.NewManager(c).GetLibraryByName(ctx, s.Options.ContentLibrary)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to find content library %v", s.Options.ContentLibrary)
+	}
+	if lib.Type == "SUBSCRIBED" {
+		return nil, errors.New(fmt.Sprintf("content library %v is subscribed, only local libraries can be uploaded to", lib.Name))
+	}
+	return lib, nil
+}
+
+// uploadToLibrary uploads the OVF descriptor of an OVA, and the files it references, to an OVF item of a content
+// library. The item is named by the LibraryItem option and defaults to the name of the template. The descriptor is
+// uploaded as <item>.ovf, so the manifest, which has the original name, isn't uploaded. The source digest is kept
+// in the description of the item, an item that already has the files of the OVA isn't uploaded again.
+func (s *Session) uploadToLibrary(ctx context.Context, lib *library.Library, ovaPath string, templateName string, source SourceInfo) (*LibraryItem, error) {
+	c, err := s.RestClient()
+	if err != nil {
+		return nil, err
+	}
+	m := library.NewManager(c)
+	name := s.Options.LibraryItem
+	if name == "" {
+		name = templateName
+	}
+	result := &LibraryItem{Library: lib.Name, Name: name, Status: LibraryItemCreated}
+	if source.Digest == "" {
+		source, err = s.source(ovaPath)
+		if err != nil {
+			return result, errors.WithMessagef(err, "unable to identify %v", ovaPath)
+		}
+	}
+	description := setMetadata("", source.metadata())
+
+	ids, err := m.FindLibraryItems(ctx, library.FindItem{LibraryID: lib.ID, Name: name})
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to find item %v in content library %v", name, lib.Name)
+	}
+	created := false
+	if len(ids) > 0 {
+		item, err := m.GetLibraryItem(ctx, ids[0])
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to get item %v of content library %v", name, lib.Name)
+		}
+		result.ID = item.ID
+		result.Version = item.ContentVersion
+		if item.Type != library.ItemTypeOVF {
+			return result, errors.New(fmt.Sprintf("item %v of content library %v is a %v, not an ovf", name, lib.Name, item.Type))
+		}
+		if getMetadata(item.Description)[MetadataSourceDigest] == source.Digest {
+			result.Status = LibraryItemExists
+			return result, nil
+		}
+		result.Status = LibraryItemUpdated
+	} else {
+		result.ID, err = m.CreateLibraryItem(ctx, library.Item{LibraryID: lib.ID, Name: name, Type: library.ItemTypeOVF, Description: description})
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to create item %v in content library %v", name, lib.Name)
+		}
+		created = true
+	}
+
+	if err := s.uploadLibraryFiles(ctx, m, result.ID, name, ovaPath); err != nil {
+		if created {
+			if cleanupErr := m.DeleteLibraryItem(ctx, &library.Item{ID: result.ID}); cleanupErr != nil {
+				return result, errors.WithMessagef(err, "the item %v could not be deleted (%v)", result.ID, cleanupErr)
+			}
+			result.ID = ""
+		}
+		return result, err
+	}
+	if !created {
+		if err := patchLibraryItem(ctx, c, result.ID, library.Item{Description: description}); err != nil {
+			return result, errors.Wrapf(err, "unable to update the description of item %v", name)
+		}
+	}
+	item, err := m.GetLibraryItem(ctx, result.ID)
+	if err != nil {
+		return result, errors.Wrapf(err, "unable to get item %v of content library %v", name, lib.Name)
+	}
+	result.Version = item.ContentVersion
+	return result, nil
+}
+
+// uploadLibraryFiles pushes the files of an OVA to a library item in an update session, they're read from the OVA as
+// they're uploaded
+func (s *Session) uploadLibraryFiles(ctx context.Context, m *library.Manager, itemID string, name string, ovaPath string) error {
+	ovaClient, err := newOVA(s.Conn, ovaPath)
+	if err != nil {
+		return errors.WithMessage(err, "unable to create ova client")
+	}
+	env, err := ovaClient.getEnvelope(ovaPath)
+	if err != nil {
+		return err
+	}
+	session, err := m.CreateLibraryItemUpdateSession(ctx, library.Session{LibraryItemID: itemID})
+	if err != nil {
+		return errors.Wrapf(err, "unable to start an update session for item %v", name)
+	}
+	files := map[string]string{name + ".ovf": "*.ovf"}
+	order := []string{name + ".ovf"}
+	for _, ref := range env.References {
+		files[ref.Href] = ref.Href
+		order = append(order, ref.Href)
+	}
+	for _, file := range order {
+		if err := s.uploadLibraryFile(ctx, m, ovaClient, session, file, files[file], ovaPath); err != nil {
+			_ = m.CancelLibraryItemUpdateSession(ctx, session)
+			return err
+		}
+	}
+	if err := m.CompleteLibraryItemUpdateSession(ctx, session); err != nil {
+		_ = m.CancelLibraryItemUpdateSession(ctx, session)
+		return errors.Wrapf(err, "unable to complete the update session of item %v", name)
+	}
+	if err := m.WaitOnLibraryItemUpdateSession(ctx, session, libraryUpdatePollInterval, nil); err != nil {
+		return errors.Wrapf(err, "the update of item %v failed", name)
+	}
+	return errors.Wrapf(m.DeleteLibraryItemUpdateSession(ctx, session), "unable to delete the update session of item %v", name)
+}
+
+// uploadLibraryFile pushes the file of an OVA matching pattern to an update session as name
+func (s *Session) uploadLibraryFile(ctx context.Context, m *library.Manager, ovaClient ova, session string, name string, pattern string, ovaPath string) error {
+	f, size, err := ovaClient.openOva(pattern, ovaPath)
+	if err != nil {
+		return errors.WithMessagef(err, "unable to open %v in %v", pattern, ovaPath)
+	}
+	defer f.Close()
+	file, err := m.AddLibraryItemFile(ctx, session, library.UpdateFile{Name: name, SourceType: "PUSH", Size: size})
+	if err != nil {
+		return errors.Wrapf(err, "unable to add %v to the update session", name)
+	}
+	u, err := url.Parse(file.UploadEndpoint.URI)
+	if err != nil {
+		return errors.Wrapf(err, "invalid upload endpoint for %v", name)
+	}
+	upload := soap.DefaultUpload
+	upload.ContentLength = size
+	return errors.Wrapf(m.Upload(ctx, f, u, &upload), "unable to upload %v", name)
+}
+
+// patchLibraryItem updates the name, description or type of a library item, the library manager has no method for it
+func patchLibraryItem(ctx context.Context, c *rest.Client, id string, item library.Item) error {
+	spec := struct {
+		Item library.Item `json:"update_spec"`
+	}{item}
+	return c.Do(ctx, c.Resource(libraryItemPath).WithID(id).Request(http.MethodPatch, spec), nil)
+}
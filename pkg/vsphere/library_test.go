@@ -0,0 +1,114 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/vmware/govmomi/vapi/library"
+)
+
+// createTestLibrary adds a content library backed by the datastore of the session
+func createTestLibrary(t *testing.T, lib library.Library) *library.Manager {
+	t.Helper()
+	c, err := sim.conn.RestClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := library.NewManager(c)
+	lib.Storage = []library.StorageBackings{{DatastoreID: sim.conn.Datastore.Reference().Value, Type: "DATASTORE"}}
+	id, err := m.CreateLibrary(sim.conn.Ctx, lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = m.DeleteLibrary(sim.conn.Ctx, &library.Library{ID: id}) })
+	return m
+}
+
+func TestDeployOVATemplateContentLibrary(t *testing.T) {
+	useTestTargets(t)
+	m := createTestLibrary(t, library.Library{Name: "ovaimporter-lib", Type: "LOCAL"})
+	sim.conn.Options.ContentLibrary = "ovaimporter-lib"
+	sim.conn.Options.LibraryItem = "lib-tiny-item"
+	ovaPath := newTestOVA(t, testOVF{Name: "lib-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := info.LibraryItem
+	if item == nil || item.ID == "" || item.Status != LibraryItemCreated || item.Name != "lib-tiny-item" || item.Library != "ovaimporter-lib" {
+		t.Fatalf("expected the item to be created, actual: %+v", item)
+	}
+	files, err := m.ListLibraryItemFiles(sim.conn.Ctx, item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "disk-0.vmdk" || names[1] != "lib-tiny-item.ovf" {
+		t.Fatalf("expected the descriptor and the disk to be uploaded, actual: %v", names)
+	}
+
+	// a re-run of the same OVA keeps the item
+	again, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.LibraryItem == nil || again.LibraryItem.ID != item.ID || again.LibraryItem.Status != LibraryItemExists {
+		t.Fatalf("expected the item to exist, actual: %+v", again.LibraryItem)
+	}
+
+	// an item of another OVA is updated
+	if err := patchLibraryItem(sim.conn.Ctx, m.Client, item.ID, library.Item{Description: setMetadata("", map[string]string{MetadataSourceDigest: "older"})}); err != nil {
+		t.Fatal(err)
+	}
+	again, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.LibraryItem == nil || again.LibraryItem.ID != item.ID || again.LibraryItem.Status != LibraryItemUpdated {
+		t.Fatalf("expected the item to be updated, actual: %+v", again.LibraryItem)
+	}
+	updated, err := m.GetLibraryItem(sim.conn.Ctx, item.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getMetadata(updated.Description)[MetadataSourceDigest] != info.Source.Digest {
+		t.Fatalf("expected the description to have the source digest, actual: %v", updated.Description)
+	}
+}
+
+func TestFindLibrary(t *testing.T) {
+	useTestTargets(t)
+	createTestLibrary(t, library.Library{Name: "ovaimporter-local", Type: "LOCAL"})
+	createTestLibrary(t, library.Library{
+		Name:         "ovaimporter-subscribed",
+		Type:         "SUBSCRIBED",
+		Subscription: &library.Subscription{SubscriptionURL: "http://publisher.example.org/cls/vcsp/lib/published"},
+	})
+	tests := map[string]struct {
+		name string
+		err  bool
+	}{
+		"local":      {"ovaimporter-local", false},
+		"subscribed": {"ovaimporter-subscribed", true},
+		"missing":    {"no-such-library", true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			sim.conn.Options.ContentLibrary = tc.name
+			lib, err := sim.conn.findLibrary(sim.conn.Ctx)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %v, actual: %v", tc.err, err)
+			}
+			if err == nil && lib.Name != tc.name {
+				t.Fatalf("expected: %v, actual: %v", tc.name, lib.Name)
+			}
+		})
+	}
+}
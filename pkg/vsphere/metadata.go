@@ -0,0 +1,70 @@
+package vsphere
+
+import (
+	"bufio"
+	"sort"
+	"strings"
+)
+
+// metadataPrefix marks the annotation lines that are written and read by ovaimporter
+const metadataPrefix = "ovaimporter."
+
+// Metadata keys stored in the annotation of an imported template
+const (
+	MetadataEULAHash   = "eulaSha256"
+	MetadataImportedAt = "importedAt"
+)
+
+// setMetadata adds or replaces ovaimporter key/value lines in a VM annotation, other lines are kept as is
+func setMetadata(annotation string, values map[string]string) string {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(annotation))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if key, _, ok := parseMetadataLine(line); ok {
+			if _, replaced := values[key]; replaced {
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	for _, key := range sortedKeys(values) {
+		if values[key] == "" {
+			continue
+		}
+		lines = append(lines, metadataPrefix+key+"="+values[key])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// getMetadata returns the ovaimporter key/value lines of a VM annotation
+func getMetadata(annotation string) map[string]string {
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(annotation))
+	for scanner.Scan() {
+		if key, value, ok := parseMetadataLine(scanner.Text()); ok {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+func parseMetadataLine(line string) (key, value string, ok bool) {
+	if !strings.HasPrefix(line, metadataPrefix) {
+		return "", "", false
+	}
+	kv := strings.SplitN(strings.TrimPrefix(line, metadataPrefix), "=", 2)
+	if len(kv) != 2 {
+		return "", "", false
+	}
+	return kv[0], kv[1], true
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,26 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"testing"
+)
+
+func TestSetMetadata(t *testing.T) {
+	annotation := setMetadata("built by packer\novaimporter.eulaSha256=old", map[string]string{MetadataEULAHash: "new"})
+	expected := "built by packer\novaimporter.eulaSha256=new"
+	if annotation != expected {
+		t.Fatalf("expected: %q, actual: %q", expected, annotation)
+	}
+	values := getMetadata(annotation)
+	if len(values) != 1 || values[MetadataEULAHash] != "new" {
+		t.Fatalf("unexpected metadata: %v", values)
+	}
+}
+
+func TestSetMetadataEmpty(t *testing.T) {
+	annotation := setMetadata("", map[string]string{})
+	if annotation != "" {
+		t.Fatalf("expected an empty annotation, actual: %q", annotation)
+	}
+}
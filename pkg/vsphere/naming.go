@@ -0,0 +1,195 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// maxNameLength is the longest name vSphere allows for a virtual machine
+const maxNameLength = 80
+
+// shortDigestLength is the number of hex characters of the source digest used by the {digest} placeholder
+const shortDigestLength = 12
+
+var (
+	namePlaceholder = regexp.MustCompile(`\{([A-Za-z]+)\}`)
+	// vSphere escapes these characters in names, they are replaced so a name reads the same in the UI and in inventory paths
+	invalidNameChars = regexp.MustCompile(`[%/\\[:cntrl:]]+`)
+)
+
+// ovaBaseName is the file name of an OVA without the .ova extension. The query string and fragment of a URL,
+// e.g. the signature of a presigned S3 URL, are not part of it.
+func ovaBaseName(ovaPath string) string {
+	name := ovaPath
+	if isRemotePath(ovaPath) {
+		if u, err := url.Parse(ovaPath); err == nil {
+			name = u.Path
+		}
+	}
+	return strings.TrimSuffix(path.Base(name), ".ova")
+}
+
+// sanitizeName replaces the characters vSphere doesn't allow in names and shortens the name to the vSphere limit
+func sanitizeName(name string) (string, error) {
+	sanitized := strings.TrimSpace(invalidNameChars.ReplaceAllString(name, "-"))
+	if runes := []rune(sanitized); len(runes) > maxNameLength {
+		sanitized = strings.TrimSpace(string(runes[:maxNameLength]))
+	}
+	if sanitized == "" {
+		return "", errors.New(fmt.Sprintf("name %q is empty once the characters vSphere doesn't allow are removed", name))
+	}
+	return sanitized, nil
+}
+
+// templateName returns the name an OVA is imported as: the Name option, the NameTemplate option with its placeholders
+// expanded, or the file name of the OVA. The OVF descriptor is returned when it had to be read for a placeholder.
+func (s *Session) templateName(ovaPath string) (string, *ovf.Envelope, error) {
+	if s.Options.Name != "" && s.Options.NameTemplate != "" {
+		return "", nil, errors.New("a name and a name template can't both be set")
+	}
+	if s.Options.Name != "" {
+		name, err := sanitizeName(s.Options.Name)
+		return name, nil, err
+	}
+	if s.Options.NameTemplate == "" {
+		name, err := sanitizeName(ovaBaseName(ovaPath))
+		return name, nil, err
+	}
+
+	var env *ovf.Envelope
+	var expandErr error
+	expanded := namePlaceholder.ReplaceAllStringFunc(s.Options.NameTemplate, func(placeholder string) string {
+		if expandErr != nil {
+			return ""
+		}
+		value, err := s.placeholderValue(strings.Trim(placeholder, "{}"), ovaPath, &env)
+		if err != nil {
+			expandErr = errors.WithMessagef(err, "unable to expand %v in name template %q", placeholder, s.Options.NameTemplate)
+		}
+		return value
+	})
+	if expandErr != nil {
+		return "", env, expandErr
+	}
+	name, err := sanitizeName(expanded)
+	return name, env, err
+}
+
+// placeholderValue returns the value of a name template placeholder, the OVF descriptor is read into env the first time it's needed
+func (s *Session) placeholderValue(placeholder string, ovaPath string, env **ovf.Envelope) (string, error) {
+	var err error
+	switch placeholder {
+	case "name":
+		return ovaBaseName(ovaPath), nil
+	case "date":
+		return time.Now().UTC().Format("20060102"), nil
+	case "digest":
+		source, err := s.source(ovaPath)
+		if err != nil {
+			return "", err
+		}
+		return source.Digest[:shortDigestLength], nil
+	case "product", "vendor", "version":
+	default:
+		return "", errors.New("unknown placeholder, must be one of {name}, {product}, {vendor}, {version}, {digest} or {date}")
+	}
+
+	if *env == nil {
+		if *env, err = s.readEnvelope(ovaPath); err != nil {
+			return "", err
+		}
+	}
+	var value string
+	if product := ovfProduct(*env); product != nil {
+		switch placeholder {
+		case "product":
+			value = product.Product
+		case "vendor":
+			value = product.Vendor
+		case "version":
+			value = product.Version
+		}
+	}
+	if value == "" {
+		return "", errors.New(fmt.Sprintf("the OVF descriptor has no product %v", placeholder))
+	}
+	return value, nil
+}
+
+// findExisting returns the virtual machine or template with a name in the target folder, its subfolders and other
+// folders aren't searched, or nil if there is none. Without a target folder the whole datacenter is searched. Names
+// are compared exactly and a name used more than once is an error.
+func (s *Session) findExisting(ctx context.Context, name string) (*object.VirtualMachine, error) {
+	root, where, recursive := s.Datacenter.Reference(), "datacenter "+s.Datacenter.Name(), true
+	if s.Folder != nil {
+		root, where, recursive = s.Folder.Reference(), "folder "+s.Folder.InventoryPath, false
+	}
+	v, err := view.NewManager(s.Conn.Client).CreateContainerView(ctx, root, []string{"VirtualMachine"}, recursive)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create a view of the vms in %v", where)
+	}
+	defer func() { _ = v.Destroy(ctx) }()
+	var vms []mo.VirtualMachine
+	if err := v.Retrieve(ctx, []string{"VirtualMachine"}, []string{"name"}, &vms); err != nil {
+		return nil, errors.Wrapf(err, "unable to get the names of the vms in %v", where)
+	}
+	var found []*object.VirtualMachine
+	var paths []string
+	for _, vm := range vms {
+		if vm.Name != name {
+			continue
+		}
+		existing := object.NewVirtualMachine(s.Conn.Client, vm.Reference())
+		if existing.InventoryPath, err = inventoryPath(ctx, s.Conn.Client, vm.Reference()); err != nil {
+			return nil, err
+		}
+		found = append(found, existing)
+		paths = append(paths, existing.InventoryPath)
+	}
+	if len(found) > 1 {
+		return nil, errors.New(fmt.Sprintf("name %v is ambiguous, it is used by %v, set a folder", name, strings.Join(paths, ", ")))
+	}
+	if len(found) == 0 {
+		return nil, nil
+	}
+	return found[0], nil
+}
+
+// inventoryPath returns the inventory path of a managed entity, the path the finder returns it with
+func inventoryPath(ctx context.Context, c *vim25.Client, ref types.ManagedObjectReference) (string, error) {
+	entities, err := mo.Ancestors(ctx, c, c.ServiceContent.PropertyCollector, ref)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to get the inventory path of %v", ref)
+	}
+	p := "/"
+	for _, entity := range entities {
+		// the root folder isn't part of the path
+		if entity.Parent == nil {
+			continue
+		}
+		p = path.Join(p, entity.Name)
+	}
+	return p, nil
+}
+
+// suffixedName appends a suffix to a name, the name is shortened so that the result stays within the vSphere limit
+func suffixedName(name string, suffix string) string {
+	if runes := []rune(name); len(runes)+len([]rune(suffix)) > maxNameLength {
+		name = string(runes[:maxNameLength-len([]rune(suffix))])
+	}
+	return name + suffix
+}
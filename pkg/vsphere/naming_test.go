@@ -0,0 +1,266 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestOVABaseName(t *testing.T) {
+	tests := map[string]string{
+		"/tmp/images/ubuntu-2004.ova": "ubuntu-2004",
+		"https://bucket.s3.amazonaws.com/images/ubuntu-2004.ova?X-Amz-Signature=abc&X-Amz-Expires=3600": "ubuntu-2004",
+		"https://example.com/downloads/Tiny%20Linux%20VM.ova#latest":                                    "Tiny Linux VM",
+	}
+	for ovaPath, expected := range tests {
+		if actual := ovaBaseName(ovaPath); actual != expected {
+			t.Errorf("%v: expected: %v, actual: %v", ovaPath, expected, actual)
+		}
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"ubuntu-2004":           "ubuntu-2004",
+		" photon/os 4.0 ":       "photon-os 4.0",
+		`100% win\dows`:         "100- win-dows",
+		"tabs\tand\nnewlines":   "tabs-and-newlines",
+		strings.Repeat("a", 90): strings.Repeat("a", maxNameLength),
+	}
+	for name, expected := range tests {
+		actual, err := sanitizeName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Errorf("%q: expected: %q, actual: %q", name, expected, actual)
+		}
+	}
+	if _, err := sanitizeName("  "); err == nil {
+		t.Fatal("expected an error for a name without allowed characters")
+	}
+}
+
+func TestDeployOVATemplateNameTemplate(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "named-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13", Product: "Tiny/OS", Version: "1.2.3"})
+	sim.conn.Options.NameTemplate = "{product}-{version}-{digest}-{date}"
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !regexp.MustCompile(`^Tiny-OS-1\.2\.3-[0-9a-f]{12}-[0-9]{8}$`).MatchString(info.TemplateName) {
+		t.Fatalf("unexpected name %v", info.TemplateName)
+	}
+	if _, err := sim.conn.GetVM(info.TemplateName); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.AlreadyExists || again.TemplateName != info.TemplateName {
+		t.Fatalf("expected %v to already exist, actual: %+v", info.TemplateName, again)
+	}
+}
+
+func TestDeployOVATemplateNameTemplateErrors(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "unnamed-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	tests := map[string]DeployOptions{
+		"unknown placeholder": {NameTemplate: "{name}-{build}"},
+		"no product section":  {NameTemplate: "{product}"},
+		"name and template":   {Name: "tiny", NameTemplate: "{name}"},
+	}
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			sim.conn.Options = options
+			if _, err := sim.conn.DeployOVATemplate(ovaPath); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestDeployOVATemplateExistsInOtherFolder(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "folder-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+	_, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the simulator doesn't make the directories of same-named VMs unique
+	createTestDatastore(t, "DC0_H0", "NamingDS")
+	sim.conn.Datastore, err = sim.conn.GetDatastoreOrDefault("/DC0/datastore/NamingDS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	folder, err := sim.conn.Folder.CreateFolder(context.Background(), "naming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Folder, err = sim.conn.GetFolderOrDefault("/DC0/vm/naming")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AlreadyExists {
+		t.Fatal("expected the template in another folder to be ignored")
+	}
+	again, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.AlreadyExists || again.VMObject.Reference() != info.VMObject.Reference() {
+		t.Fatalf("expected the template in folder %v to exist", folder.Reference())
+	}
+
+	sim.conn.Folder = nil
+	if _, err := sim.conn.findExisting(context.Background(), "folder-tiny"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Fatalf("expected an ambiguous name error, actual: %v", err)
+	}
+}
+
+func TestFindExisting(t *testing.T) {
+	useTestTargets(t)
+	ctx := context.Background()
+	sub, err := sim.conn.Folder.CreateFolder(ctx, "find")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, err := sub.CreateFolder(ctx, "nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := sim.conn.GetVM("DC0_H0_VM0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := sim.conn.ResourcePool.Reference()
+	task, err := vm.Clone(ctx, nested, "find-nested-vm", types.VirtualMachineCloneSpec{Location: types.VirtualMachineRelocateSpec{Pool: &pool}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := task.WaitForResult(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	findFolder, err := sim.conn.GetFolderOrDefault("/DC0/vm/find")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nestedFolder, err := sim.conn.GetFolderOrDefault("/DC0/vm/find/nested")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		folder   *object.Folder
+		name     string
+		expected string
+	}{
+		"exact name":        {nil, "DC0_H0_VM0", "/DC0/vm/DC0_H0_VM0"},
+		"star":              {nil, "DC0_H0_VM*", ""},
+		"question mark":     {nil, "DC0_H0_VM?", ""},
+		"character class":   {nil, "DC0_H0_VM[01]", ""},
+		"datacenter":        {nil, "find-nested-vm", "/DC0/vm/find/nested/find-nested-vm"},
+		"folder":            {nestedFolder, "find-nested-vm", "/DC0/vm/find/nested/find-nested-vm"},
+		"subfolder":         {findFolder, "find-nested-vm", ""},
+		"outside of folder": {findFolder, "DC0_H0_VM0", ""},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			sim.conn.Folder = tc.folder
+			existing, err := sim.conn.findExisting(ctx, tc.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := ""
+			if existing != nil {
+				actual = existing.InventoryPath
+			}
+			if actual != tc.expected {
+				t.Fatalf("expected: %q, actual: %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDeployOVATemplateSameNameInOtherFolders(t *testing.T) {
+	useTestTargets(t)
+	ctx := context.Background()
+	target, err := sim.conn.Folder.CreateFolder(ctx, "same-name-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := target.CreateFolder(ctx, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sibling, err := sim.conn.Folder.CreateFolder(ctx, "same-name-sibling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the simulator doesn't make the directories of same-named VMs unique
+	createTestDatastore(t, "DC0_H0", "SameNameDS")
+	ds, err := sim.conn.GetDatastoreOrDefault("/DC0/datastore/SameNameDS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := sim.conn.GetVM("DC0_H0_VM0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetFolder, err := sim.conn.GetFolderOrDefault("/DC0/vm/same-name-target")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]*object.Folder{
+		"sibling folder": sibling,
+		"subfolder":      sub,
+	}
+	for name, folder := range tests {
+		t.Run(name, func(t *testing.T) {
+			vmName := "same-name-" + strings.Replace(name, " ", "-", -1)
+			pool := sim.conn.ResourcePool.Reference()
+			dsRef := ds.Reference()
+			task, err := vm.Clone(ctx, folder, vmName, types.VirtualMachineCloneSpec{Location: types.VirtualMachineRelocateSpec{Pool: &pool, Datastore: &dsRef}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := task.WaitForResult(ctx, nil); err != nil {
+				t.Fatal(err)
+			}
+			sim.conn.Folder = targetFolder
+			ovaPath := newTestOVA(t, testOVF{Name: vmName, OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+			info, err := sim.conn.DeployOVATemplate(ovaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.AlreadyExists {
+				t.Fatalf("expected the VM in the %v to be ignored", name)
+			}
+			imported, err := sim.conn.GetVM("/DC0/vm/same-name-target/" + vmName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if imported.Reference() != info.VMObject.Reference() {
+				t.Fatalf("expected the import %v in the target folder, actual: %v", info.VMObject.Reference(), imported.Reference())
+			}
+		})
+	}
+}
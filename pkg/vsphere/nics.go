@@ -0,0 +1,155 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// NIC policies applied to the imported VM before it is marked as a template
+const (
+	NICPolicyKeep    = "keep"
+	NICPolicyRemove  = "remove"
+	NICPolicyReplace = "replace"
+)
+
+// NIC describes a network adapter of an imported VM
+type NIC struct {
+	Label       string `json:"label"`
+	AdapterType string `json:"adapterType"`
+	Network     string `json:"network,omitempty"`
+	MacAddress  string `json:"macAddress,omitempty"`
+	AddressType string `json:"addressType,omitempty"`
+}
+
+func (o DeployOptions) nicPolicy() string {
+	if o.NICPolicy == "" {
+		return NICPolicyKeep
+	}
+	return o.NICPolicy
+}
+
+func (o DeployOptions) nicAdapterType() string {
+	if o.NICAdapterType == "" {
+		return "vmxnet3"
+	}
+	return o.NICAdapterType
+}
+
+// validateNICPolicy checks the NIC options before anything is uploaded
+func (o DeployOptions) validateNICPolicy() error {
+	switch o.nicPolicy() {
+	case NICPolicyKeep, NICPolicyRemove:
+	case NICPolicyReplace:
+		if _, err := object.EthernetCardTypes().CreateEthernetCard(o.nicAdapterType(), nil); err != nil {
+			return errors.Wrap(err, "invalid NIC adapter type")
+		}
+	default:
+		return errors.New(fmt.Sprintf("unknown NIC policy %v, must be keep, remove or replace", o.NICPolicy))
+	}
+	return nil
+}
+
+// applyNICPolicy keeps, removes or replaces the NICs of a VM according to the NICPolicy option
+func (s *Session) applyNICPolicy(ctx context.Context, vm *object.VirtualMachine) error {
+	switch s.Options.nicPolicy() {
+	case NICPolicyRemove:
+		return removeNICs(ctx, vm)
+	case NICPolicyReplace:
+		return s.replaceNICs(ctx, vm)
+	}
+	return nil
+}
+
+// replaceNICs swaps every NIC of a VM for one of the NICAdapterType option, connected to the NICNetwork option
+// (or the session's network) with a generated MAC address. NICs already of that type are edited in place.
+func (s *Session) replaceNICs(ctx context.Context, vm *object.VirtualMachine) error {
+	network := s.Options.NICNetwork
+	if network == nil {
+		network = s.Network
+	}
+	if network == nil {
+		return errors.New("no network specified to reconnect the NICs to")
+	}
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get devices of vm %s (%s)", vm.InventoryPath, vm.Reference())
+	}
+
+	var changes []types.BaseVirtualDeviceConfigSpec
+	newKey := int32(-1)
+	for _, device := range devices.SelectByType((*types.VirtualEthernetCard)(nil)) {
+		backing, err := network.EthernetCardBackingInfo(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to get backing of network %v", network.Reference())
+		}
+		card := device.(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+		if nicAdapterType(device) == s.Options.nicAdapterType() {
+			card.Backing = backing
+			card.AddressType = string(types.VirtualEthernetCardMacTypeGenerated)
+			card.MacAddress = ""
+			changes = append(changes, &types.VirtualDeviceConfigSpec{Operation: types.VirtualDeviceConfigSpecOperationEdit, Device: device})
+			continue
+		}
+
+		replacement, err := devices.CreateEthernetCard(s.Options.nicAdapterType(), backing)
+		if err != nil {
+			return errors.Wrap(err, "unable to create NIC")
+		}
+		newCard := replacement.(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+		newCard.Key = newKey
+		newKey--
+		newCard.Connectable = card.Connectable
+		newCard.AddressType = string(types.VirtualEthernetCardMacTypeGenerated)
+		changes = append(changes,
+			&types.VirtualDeviceConfigSpec{Operation: types.VirtualDeviceConfigSpecOperationRemove, Device: device},
+			&types.VirtualDeviceConfigSpec{Operation: types.VirtualDeviceConfigSpecOperationAdd, Device: replacement},
+		)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return reconfigure(ctx, vm, types.VirtualMachineConfigSpec{DeviceChange: changes})
+}
+
+// vmNICs returns the NIC layout of a VM
+func vmNICs(ctx context.Context, vm *object.VirtualMachine) ([]NIC, error) {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get devices of vm %s (%s)", vm.InventoryPath, vm.Reference())
+	}
+	nics := []NIC{}
+	for _, device := range devices.SelectByType((*types.VirtualEthernetCard)(nil)) {
+		card := device.(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+		nic := NIC{
+			Label:       devices.Name(device),
+			AdapterType: nicAdapterType(device),
+			MacAddress:  card.MacAddress,
+			AddressType: card.AddressType,
+		}
+		if card.DeviceInfo != nil {
+			nic.Label = card.DeviceInfo.GetDescription().Label
+		}
+		switch backing := card.Backing.(type) {
+		case *types.VirtualEthernetCardNetworkBackingInfo:
+			nic.Network = backing.DeviceName
+		case *types.VirtualEthernetCardDistributedVirtualPortBackingInfo:
+			nic.Network = backing.Port.PortgroupKey
+		case *types.VirtualEthernetCardOpaqueNetworkBackingInfo:
+			nic.Network = backing.OpaqueNetworkId
+		}
+		nics = append(nics, nic)
+	}
+	return nics, nil
+}
+
+// nicAdapterType returns the adapter type of a NIC as used by govc (e1000, e1000e, vmxnet3, ...)
+func nicAdapterType(device types.BaseVirtualDevice) string {
+	return strings.TrimPrefix(strings.ToLower(reflect.TypeOf(device).Elem().Name()), "virtual")
+}
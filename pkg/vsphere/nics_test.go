@@ -0,0 +1,74 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"testing"
+)
+
+func TestDeployOVATemplateNICPolicy(t *testing.T) {
+	tests := map[string]struct {
+		policy   string
+		expected []NIC
+	}{
+		"keep":    {NICPolicyKeep, []NIC{{AdapterType: "e1000", Network: "VM Network"}}},
+		"remove":  {NICPolicyRemove, []NIC{}},
+		"replace": {NICPolicyReplace, []NIC{{AdapterType: "vmxnet3", Network: "DC0_DVPG0", AddressType: "generated"}}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useTestTargets(t)
+			sim.conn.Options.NICPolicy = tc.policy
+			network, err := sim.conn.GetNetworkOrDefault("DC0_DVPG0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			sim.conn.Options.NICNetwork = network
+			ovaPath := newTestOVA(t, testOVF{Name: "nic-" + name, OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+			info, err := sim.conn.DeployOVATemplate(ovaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(info.NICs) != len(tc.expected) {
+				t.Fatalf("expected: %v, actual: %v", tc.expected, info.NICs)
+			}
+			for i, nic := range info.NICs {
+				expected := tc.expected[i]
+				if nic.AdapterType != expected.AdapterType {
+					t.Fatalf("expected: %v, actual: %v", expected.AdapterType, nic.AdapterType)
+				}
+				if expected.AddressType != "" && nic.AddressType != expected.AddressType {
+					t.Fatalf("expected: %v, actual: %v", expected.AddressType, nic.AddressType)
+				}
+				if expected.Network == "VM Network" && nic.Network != expected.Network {
+					t.Fatalf("expected: %v, actual: %v", expected.Network, nic.Network)
+				}
+				if expected.Network != "VM Network" && nic.Network != network.Reference().Value {
+					t.Fatalf("expected: %v, actual: %v", network.Reference().Value, nic.Network)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNICPolicy(t *testing.T) {
+	tests := map[string]struct {
+		options DeployOptions
+		errMsg  string
+	}{
+		"unknown policy":       {DeployOptions{NICPolicy: "rename"}, "unknown NIC policy rename, must be keep, remove or replace"},
+		"unknown adapter type": {DeployOptions{NICPolicy: NICPolicyReplace, NICAdapterType: "rtl8139"}, "invalid NIC adapter type: unknown ethernet card type 'rtl8139'"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tc.options.validateNICPolicy()
+			if err == nil {
+				t.Fatal("received an unexpected nil error")
+			}
+			if err.Error() != tc.errMsg {
+				t.Fatalf("expected: %v, actual: %v", tc.errMsg, err.Error())
+			}
+		})
+	}
+}
@@ -2,40 +2,200 @@ package vsphere
 
 import (
 	"archive/tar"
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 
 	"github.com/vmware/govmomi"
-	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/nfc"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/vapi/library"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/soap"
 	"github.com/vmware/govmomi/vim25/types"
 )
 
+// Kinds of inventory objects an OVA is imported as
+const (
+	KindTemplate = "template"
+	KindVM       = "vm"
+)
+
+// Import modes, what DeployOVATemplate leaves behind
+const (
+	ModeTemplate  = "template"
+	ModeVM        = "vm"
+	ModeVMPowerOn = "vm-poweron"
+)
+
 // DeployInfo is data for a deployed OVA
 type DeployInfo struct {
 	TemplateName  string
 	VMObject      *object.VirtualMachine
 	AlreadyExists bool
+	Preflight     PreflightResult
+	EULAHash      string
+	// Kind is KindTemplate or KindVM, depending on the Mode option
+	Kind string
+	// StoragePolicyID is the ID of the storage policy applied to the VM home and disks
+	StoragePolicyID string
+	// KeyProvider is the ID of the key provider the VM is encrypted with, empty if it isn't encrypted
+	KeyProvider string
+	// NICs is the network adapter layout after the NIC policy was applied
+	NICs []NIC
+	// Snapshot is the snapshot named by the Snapshot option, for linked clones
+	Snapshot *types.ManagedObjectReference
+
+	// SmokeTest is the outcome of the boot test of the template, nil if none was run
+	SmokeTest *SmokeTestResult
+	// Replaced is the template that was replaced by the import, nil if none was
+	Replaced *ReplaceResult
+	// Source identifies the OVA, it is recorded on new templates and compared with existing ones
+	Source SourceInfo
+	// Drift of an existing template from the OVA, upToDate, drifted or unknown
+	Drift string
+	// Family and Version the template is tagged with, empty without the Family option
+	Family  string
+	Version string
+	// Replicas of the template on the datastores of the ReplicateTo option
+	Replicas []Replica
+	// LibraryItem the OVA was uploaded to, nil without the ContentLibrary option
+	LibraryItem *LibraryItem
+}
+
+// DeployOptions changes the default behaviour of DeployOVATemplate
+type DeployOptions struct {
+	// Name the OVA is imported as, defaults to the file name of the OVA without the .ova extension
+	Name string
+	// NameTemplate is a name with the placeholders {name}, {product}, {vendor}, {version}, {digest} and {date},
+	// which are the OVA file name, the OVF product section values, the start of the source digest and the date (YYYYMMDD)
+	NameTemplate string
+	// SkipPreflight disables the compatibility checks run before the upload
+	SkipPreflight bool
+	// AcceptEULA must be set to import an OVA that has a EulaSection
+	AcceptEULA bool
+	// DiskProvisioning is the disk format of the imported VM, defaults to thin
+	DiskProvisioning string
+	// MinFreePercent of the datastore capacity that must still be free after the import
+	MinFreePercent float64
+	// StoragePolicy is the name of a storage policy (SPBM) applied to the VM home and disks
+	StoragePolicy string
+	// Encrypt the VM with a key of KeyProvider, or with the encryption of StoragePolicy when KeyProvider is empty
+	Encrypt bool
+	// KeyProvider is the ID of the key provider used for encryption, defaults to the vCenter's default key provider
+	KeyProvider string
+	// Reconfig is applied to the VM after the import
+	Reconfig Reconfig
+	// GuestInfo values are added to the extraConfig of the VM as base64 encoded guestinfo.<key>
+	GuestInfo map[string]string
+	// NICPolicy is keep (default), remove or replace
+	NICPolicy string
+	// NICAdapterType is the adapter type NICs are replaced with, defaults to vmxnet3
+	NICAdapterType string
+	// NICNetwork is the network replaced NICs are connected to, defaults to the session's network
+	NICNetwork object.NetworkReference
+	// Mode is template, vm or vm-poweron. It defaults to template, or vm on standalone ESXi hosts which have no templates.
+	Mode string
+	// SmokeTest boots a clone of the imported template, which is deleted or quarantined if it doesn't come up
+	SmokeTest bool
+	// SmokeTestTimeout is how long to wait for VMware Tools, an IP and SmokeTestPort, defaults to what is left of the
+	// session's context, or 10 minutes when it has no deadline. 30 seconds of the context are kept for the cleanup.
+	SmokeTestTimeout time.Duration
+	// SmokeTestPort is a TCP port probed on the IP of the clone, 0 disables the probe
+	SmokeTestPort int
+	// SmokeTestFailure is what happens to a template that fails the smoke test, delete (default) or quarantine
+	SmokeTestFailure string
+	// Snapshot is the name of a snapshot taken before the VM is marked as a template, it is kept if it already exists
+	Snapshot string
+	// Replace an existing template with a new import of the OVA instead of keeping it
+	Replace bool
+	// ReplaceOld is what happens to the replaced template, delete (default) or keep
+	ReplaceOld string
+	// OnDrift is what happens to an existing template that drifted from the OVA, skip (default), fail or replace
+	OnDrift string
+	// Family of images the template is tagged with, for retention by GarbageCollect
+	Family string
+	// Version the template is tagged with in its family, defaults to the OVF product version or the start of the source digest
+	Version string
+	// ReplicateTo clones the template to datastores: all-accessible, regex:<pattern> or a comma separated list of names
+	ReplicateTo string
+	// ReplicaConcurrency is the number of replicas cloned at the same time, defaults to 4
+	ReplicaConcurrency int
+	// ContentLibrary is the name of a local content library the OVA is also uploaded to, as an OVF item
+	ContentLibrary string
+	// LibraryItem is the name of the content library item, defaults to the name of the template
+	LibraryItem string
+}
+
+func (o DeployOptions) diskProvisioning() string {
+	if o.DiskProvisioning == "" {
+		return string(types.OvfCreateImportSpecParamsDiskProvisioningTypeThin)
+	}
+	return o.DiskProvisioning
+}
+
+// thinProvisioned is true for the disk formats that only allocate the populated size of a disk
+func (o DeployOptions) thinProvisioned() bool {
+	p := o.diskProvisioning()
+	return p == string(types.OvfCreateImportSpecParamsDiskProvisioningTypeThin) || strings.Contains(strings.ToLower(p), "sparse")
+}
+
+// mode returns the Mode option or its default for the session
+func (s *Session) mode() string {
+	switch {
+	case s.Options.Mode != "":
+		return s.Options.Mode
+	case s.IsESXi():
+		return ModeVM
+	default:
+		return ModeTemplate
+	}
+}
+
+// validateMode checks the Mode option before anything is uploaded
+func (s *Session) validateMode() error {
+	switch s.mode() {
+	case ModeTemplate:
+		if s.IsESXi() {
+			return errors.New("standalone ESXi hosts have no templates, use mode vm or vm-poweron")
+		}
+	case ModeVM, ModeVMPowerOn:
+	default:
+		return errors.New(fmt.Sprintf("unknown mode %v, must be template, vm or vm-poweron", s.Options.Mode))
+	}
+	return nil
+}
+
+// kind is the kind of object a mode creates
+func kind(mode string) string {
+	if mode == ModeTemplate {
+		return KindTemplate
+	}
+	return KindVM
 }
 
 // DeployOVATemplates deploys multiple OVAs asynchronously
 func (s *Session) DeployOVATemplates(templatePaths ...string) (map[string]DeployInfo, error) {
 	templatePaths = sliceDedup(templatePaths)
+	if s.Options.Name != "" && len(templatePaths) > 1 {
+		return nil, errors.New("a name can only be set when a single OVA is deployed")
+	}
 	numOVAs := len(templatePaths)
 	result := make(map[string]DeployInfo, numOVAs)
 	resultMutex := sync.Mutex{}
@@ -64,23 +224,311 @@ func (s *Session) DeployOVATemplates(templatePaths ...string) (map[string]Deploy
 
 }
 
-// DeployOVATemplate uploads ova and makes it a template
+// DeployOVATemplate uploads ova and makes it a template, or leaves it as a VM as set by the Mode option.
+// The template is then cloned to the datastores of the ReplicateTo option, and the OVA is uploaded to the
+// ContentLibrary option.
 func (s *Session) DeployOVATemplate(templatePath string) (DeployInfo, error) {
+	ctx := s.Ctx
+	// the replica datastores and the content library are checked before the import
+	var datastores []datastoreCandidate
+	var err error
+	if s.Options.ReplicateTo != "" {
+		datastores, err = s.replicaDatastores(ctx)
+		if err != nil {
+			return DeployInfo{}, err
+		}
+	}
+	var lib *library.Library
+	if s.Options.ContentLibrary != "" {
+		lib, err = s.findLibrary(ctx)
+		if err != nil {
+			return DeployInfo{}, err
+		}
+	}
+	result, err := s.deployOVATemplate(ctx, templatePath)
+	if err != nil {
+		return result, err
+	}
+	if s.Options.ReplicateTo != "" {
+		result.Replicas, err = s.replicate(ctx, result.VMObject, result.TemplateName, datastores)
+		if err != nil {
+			return result, err
+		}
+	}
+	if lib != nil {
+		result.LibraryItem, err = s.uploadToLibrary(ctx, lib, templatePath, result.TemplateName, result.Source)
+	}
+	return result, err
+}
+
+func (s *Session) deployOVATemplate(ctx context.Context, templatePath string) (DeployInfo, error) {
 	// TODO validate session has no nil values
 	var result DeployInfo
-	templateName := strings.TrimSuffix(path.Base(templatePath), ".ova")
+	if err := s.validateMode(); err != nil {
+		return result, err
+	}
+	templateName, env, err := s.templateName(templatePath)
+	if err != nil {
+		return result, errors.WithMessagef(err, "unable to name the import of %v", templatePath)
+	}
 	result.TemplateName = templateName
-	ctx := context.TODO()
-	vSphereClient := s.Conn
-	finder := find.NewFinder(vSphereClient.Client, true)
-	finder.SetDatacenter(s.Datacenter)
-	foundTemplate, err := finder.VirtualMachine(ctx, templateName)
-	if err == nil {
+	foundTemplate, err := s.findExisting(ctx, templateName)
+	if err != nil {
+		return result, err
+	}
+	if foundTemplate != nil && !s.Options.Replace {
 		result.AlreadyExists = true
 		result.VMObject = foundTemplate
+		isTemplate, err := foundTemplate.IsTemplate(ctx)
+		if err != nil {
+			return result, errors.Wrapf(err, "unable to check if %v is a template", templateName)
+		}
+		result.Kind = KindVM
+		if isTemplate {
+			result.Kind = KindTemplate
+		}
+		if expected := kind(s.mode()); result.Kind != expected {
+			return result, errors.New(fmt.Sprintf("%v already exists as a %v, not a %v", templateName, result.Kind, expected))
+		}
+		if err := s.Options.validateOnDrift(); err != nil {
+			return result, err
+		}
+		result.Drift, result.Source, err = s.checkDrift(ctx, foundTemplate, templatePath)
+		if err != nil {
+			// the existing template is kept anyway, an OVA that can't be read, e.g. an expired URL, doesn't fail the run
+			if s.Options.onDrift() != OnDriftSkip {
+				return result, errors.WithMessagef(err, "unable to check %v for drift", templateName)
+			}
+			result.Drift = DriftUnknown
+		}
+		if result.Drift == DriftDrifted {
+			switch s.Options.onDrift() {
+			case OnDriftFail:
+				return result, errors.New(fmt.Sprintf("%v has drifted from %v", templateName, templatePath))
+			case OnDriftReplace:
+				result = DeployInfo{TemplateName: templateName, Drift: result.Drift}
+				err = s.replace(ctx, foundTemplate, templatePath, templateName, env, &result)
+				return result, err
+			}
+		}
+		if s.Options.Snapshot != "" {
+			result.Snapshot, err = findSnapshot(ctx, foundTemplate, s.Options.Snapshot)
+			if err != nil {
+				return result, errors.WithMessagef(err, "unable to find snapshot of %v", templateName)
+			}
+		}
 		return result, nil
 	}
 
+	if foundTemplate != nil {
+		err = s.replace(ctx, foundTemplate, templatePath, templateName, env, &result)
+		return result, err
+	}
+	err = s.importOVA(ctx, templatePath, templateName, env, &result)
+	return result, err
+}
+
+// importOVA uploads an OVA as a new VM named templateName and makes it a template, or leaves it as a VM as set by the Mode option.
+// env is the OVF descriptor of the OVA, it is read when it's nil.
+func (s *Session) importOVA(ctx context.Context, templatePath string, templateName string, env *ovf.Envelope, result *DeployInfo) error {
+	var err error
+	if err := s.Options.validateNICPolicy(); err != nil {
+		return err
+	}
+	if err := s.validateSmokeTest(); err != nil {
+		return err
+	}
+	if err := s.validateFamily(); err != nil {
+		return err
+	}
+	if err := s.Options.Reconfig.validate(); err != nil {
+		return errors.WithMessagef(err, "unable to reconfigure virtual machine %v", templateName)
+	}
+	reconfig := s.Options.Reconfig
+	if len(s.Options.GuestInfo) > 0 {
+		guestInfo, err := guestInfoExtraConfig(s.Options.GuestInfo)
+		if err != nil {
+			return err
+		}
+		reconfig.ExtraConfig = make(map[string]string, len(s.Options.Reconfig.ExtraConfig)+len(guestInfo))
+		for _, values := range []map[string]string{s.Options.Reconfig.ExtraConfig, guestInfo} {
+			for k, v := range values {
+				reconfig.ExtraConfig[k] = v
+			}
+		}
+	}
+
+	if env == nil {
+		env, err = s.readEnvelope(templatePath)
+		if err != nil {
+			return errors.WithMessagef(err, "unable to read OVF descriptor of %v", templateName)
+		}
+	}
+
+	if s.Options.StoragePolicy != "" {
+		result.StoragePolicyID, err = s.storagePolicyID(ctx, s.Options.StoragePolicy)
+		if err != nil {
+			return err
+		}
+		if err := s.checkStoragePolicy(ctx, result.StoragePolicyID); err != nil {
+			return err
+		}
+	}
+	// without a key provider the import is encrypted by the storage policy, which must have encryption
+	policyEncryption := s.Options.Encrypt && s.Options.KeyProvider == "" && result.StoragePolicyID != ""
+	if policyEncryption {
+		encrypts, err := s.storagePolicyEncrypts(ctx, result.StoragePolicyID)
+		if err != nil {
+			return errors.WithMessagef(err, "unable to encrypt %v", templateName)
+		}
+		if !encrypts {
+			return errors.New(fmt.Sprintf("unable to encrypt %v: storage policy %v has no encryption, a key provider must be given", templateName, s.Options.StoragePolicy))
+		}
+	}
+
+	if s.Options.Encrypt {
+		result.KeyProvider, err = s.keyProvider(ctx)
+		if err != nil {
+			return errors.WithMessagef(err, "unable to encrypt %v", templateName)
+		}
+	}
+
+	if !s.Options.SkipPreflight {
+		if err := s.preflight(ctx, env, &result.Preflight); err != nil {
+			return errors.WithMessagef(err, "unable to run preflight checks for %v", templateName)
+		}
+		if err := result.Preflight.Err(); err != nil {
+			return err
+		}
+	}
+
+	result.Source, err = s.source(templatePath)
+	if err != nil {
+		return errors.WithMessagef(err, "unable to read the source of %v", templateName)
+	}
+	metadata := result.Source.metadata()
+	metadata[MetadataImportedAt] = time.Now().UTC().Format(time.RFC3339)
+	if s.Options.Family != "" {
+		result.Family = s.Options.Family
+		result.Version = s.Options.familyVersion(env, result.Source)
+		metadata[MetadataFamily] = result.Family
+		metadata[MetadataVersion] = result.Version
+	}
+	if eulas := ovfEULAs(env); len(eulas) > 0 {
+		if !s.Options.AcceptEULA {
+			return errors.New(fmt.Sprintf("%v has a EULA that must be accepted before it can be imported", templateName))
+		}
+		result.EULAHash = eulaHash(eulas)
+		metadata[MetadataEULAHash] = result.EULAHash
+	}
+
+	// a storage policy with encryption uses the default key provider itself, otherwise a key is generated for the VM
+	var crypto *types.CryptoSpecEncrypt
+	if s.Options.Encrypt && !policyEncryption {
+		crypto, err = s.encryptionSpec(ctx, result.KeyProvider)
+		if err != nil {
+			return errors.WithMessagef(err, "unable to encrypt %v", templateName)
+		}
+	}
+
+	cisp := s.importSpecParams(templateName)
+
+	customize := func(spec *types.VirtualMachineImportSpec) error {
+		spec.ConfigSpec.Annotation = setMetadata(spec.ConfigSpec.Annotation, metadata)
+		if result.StoragePolicyID != "" {
+			applyStoragePolicy(spec, result.StoragePolicyID)
+		}
+		if crypto != nil {
+			applyCryptoSpec(spec, crypto)
+		}
+		return nil
+	}
+
+	vm, err := createVirtualMachine(ctx, cisp, templatePath, s, customize)
+	if err != nil {
+		return errors.WithMessagef(err, "unable to create virtual machine from %v", templateName)
+	}
+
+	result.VMObject = vm
+	if err := s.configureImport(ctx, vm, templateName, reconfig, result); err != nil {
+		// a template that failed its smoke test was already deleted or quarantined
+		if result.SmokeTest != nil && !result.SmokeTest.Passed {
+			return err
+		}
+		// a half configured import would be found as an existing template by the next run
+		if cleanupErr := destroyVM(ctx, vm); cleanupErr != nil {
+			return errors.WithMessagef(err, "the import %v could not be deleted (%v)", vm.Reference().Value, cleanupErr)
+		}
+		result.VMObject = nil
+		return errors.WithMessage(err, "the import was deleted")
+	}
+	return nil
+}
+
+// configureImport reconfigures, snapshots, marks as template, tags, smoke tests and powers on a new import as set by
+// the options
+func (s *Session) configureImport(ctx context.Context, vm *object.VirtualMachine, templateName string, reconfig Reconfig, result *DeployInfo) error {
+	var err error
+	if !reconfig.IsZero() {
+		if err := reconfigureVM(ctx, vm, reconfig); err != nil {
+			return errors.WithMessagef(err, "unable to reconfigure virtual machine %v", templateName)
+		}
+	}
+
+	// Keep, remove or replace the NICs of the virtual machine before marking it as template
+	if err := s.applyNICPolicy(ctx, vm); err != nil {
+		return errors.WithMessagef(err, "unable to apply NIC policy %v to %v", s.Options.nicPolicy(), templateName)
+	}
+	result.NICs, err = vmNICs(ctx, vm)
+	if err != nil {
+		return err
+	}
+
+	if s.Options.Snapshot != "" {
+		result.Snapshot, err = ensureSnapshot(ctx, vm, s.Options.Snapshot)
+		if err != nil {
+			return errors.WithMessagef(err, "unable to snapshot virtual machine %v", templateName)
+		}
+	}
+
+	mode := s.mode()
+	if mode == ModeTemplate {
+		if err := vm.MarkAsTemplate(ctx); err != nil {
+			return errors.Wrapf(err, "unable to mark virtual machine as a template %v", templateName)
+		}
+	}
+	result.Kind = kind(mode)
+
+	if s.Options.SmokeTest {
+		smokeTest := s.smokeTest(ctx, vm, templateName)
+		result.SmokeTest = &smokeTest
+		if !smokeTest.Passed {
+			return errors.New(fmt.Sprintf("smoke test of %v failed, the %v was %v: %v", templateName, result.Kind, smokeTest.Action, smokeTest.Error))
+		}
+	}
+
+	// only a template that passed its smoke test joins the family, gc and promote don't see a quarantined one
+	if result.Family != "" {
+		if err := s.tagFamily(ctx, vm, result.Family, result.Version); err != nil {
+			return errors.WithMessagef(err, "unable to tag %v with its family", templateName)
+		}
+	}
+
+	if mode == ModeVMPowerOn {
+		task, err := vm.PowerOn(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to power on virtual machine %v", templateName)
+		}
+		if err := task.Wait(ctx); err != nil {
+			return errors.Wrapf(err, "failed waiting on power on task for %v", templateName)
+		}
+	}
+
+	return nil
+}
+
+// importSpecParams are the parameters used to create the import spec of an OVA
+func (s *Session) importSpecParams(entityName string) types.OvfCreateImportSpecParams {
 	networks := []types.OvfNetworkMapping{
 		{
 			Name:    "nic0",
@@ -88,9 +536,9 @@ func (s *Session) DeployOVATemplate(templatePath string) (DeployInfo, error) {
 		},
 	}
 
-	cisp := types.OvfCreateImportSpecParams{
-		DiskProvisioning:   "thin",
-		EntityName:         templateName,
+	return types.OvfCreateImportSpecParams{
+		DiskProvisioning:   s.Options.diskProvisioning(),
+		EntityName:         entityName,
 		IpAllocationPolicy: "dhcpPolicy",
 		IpProtocol:         "IPv4",
 		OvfManagerCommonParams: types.OvfManagerCommonParams{
@@ -101,32 +549,11 @@ func (s *Session) DeployOVATemplate(templatePath string) (DeployInfo, error) {
 		// govmomi complains that the network spec is missing otherwise (can't create the import spec).
 		NetworkMapping: networks,
 	}
-
-	vm, err := createVirtualMachine(ctx, cisp, templatePath, s)
-	if err != nil {
-		return result, errors.WithMessagef(err, "unable to create virtual machine from %v", templateName)
-	}
-
-	// Remove NICs from virtual machine before marking it as template
-
-	/*
-		if err := removeNICs(ctx, vm); err != nil {
-			return nil, errors.WithMessagef(err, "unable to remove NICs from template %v", templateName)
-		}
-	*/
-
-	if err := vm.MarkAsTemplate(ctx); err != nil {
-		return result, errors.Wrapf(err, "unable to mark virtual machine as a template %v", templateName)
-	}
-	result.VMObject = vm
-
-	return result, nil
 }
 
-func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecParams, ovaPath string, vSphere *Session) (*object.VirtualMachine, error) {
-	vSphereClient := vSphere.Conn
-
-	ovaClient, err := newOVA(vSphereClient, ovaPath)
+// createVirtualMachine imports the OVA, customize is called with the import spec before the upload starts
+func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecParams, ovaPath string, vSphere *Session, customize func(*types.VirtualMachineImportSpec) error) (*object.VirtualMachine, error) {
+	ovaClient, err := newOVA(vSphere.Conn, ovaPath)
 	if err != nil {
 		return nil, errors.WithMessage(err, "unable to create ova client")
 	}
@@ -135,8 +562,19 @@ func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecPar
 	if err != nil {
 		return nil, errors.WithMessagef(err, "unable to create import spec for template (%s)", ovaPath)
 	}
+	if err := prepareImportSpec(spec, customize); err != nil {
+		return nil, err
+	}
+
+	return importVApp(ctx, vSphere, spec, func(lease *nfc.Lease, item nfc.FileItem) error {
+		return ovaClient.upload(ctx, lease, item, ovaPath)
+	})
+}
+
+// prepareImportSpec checks an import spec and drops its OVF sections, customize is called with the VM's import spec
+func prepareImportSpec(spec *types.OvfCreateImportSpecResult, customize func(*types.VirtualMachineImportSpec) error) error {
 	if spec.Error != nil {
-		return nil, errors.New(fmt.Sprintf("unable to create import spec for template, %v", spec.Error))
+		return errors.New(fmt.Sprintf("unable to create import spec for template, %v", spec.Error))
 	}
 	switch s := spec.ImportSpec.(type) {
 	case *types.VirtualMachineImportSpec:
@@ -145,9 +583,19 @@ func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecPar
 				s.ConfigSpec.VAppConfig.GetVmConfigSpec().OvfSection = nil
 			}
 		}
+		if customize != nil {
+			if err := customize(s); err != nil {
+				return errors.WithMessage(err, "unable to customize import spec")
+			}
+		}
 	}
+	return nil
+}
 
-	lease, err := vSphere.ResourcePool.ImportVApp(ctx, spec.ImportSpec, vSphere.Folder, nil)
+// importVApp creates the VM of an import spec in the session's folder and resource pool, upload is called for each
+// file of the import lease
+func importVApp(ctx context.Context, vSphere *Session, spec *types.OvfCreateImportSpecResult, upload func(*nfc.Lease, nfc.FileItem) error) (*object.VirtualMachine, error) {
+	lease, err := vSphere.ResourcePool.ImportVApp(ctx, spec.ImportSpec, vSphere.Folder, vSphere.Host)
 	if err != nil {
 		return nil, errors.Wrap(err, "1 unable to import the template")
 	}
@@ -161,8 +609,9 @@ func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecPar
 	defer u.Done()
 
 	for _, i := range info.Items {
-		err = ovaClient.upload(ctx, lease, i, ovaPath)
+		err = upload(lease, i)
 		if err != nil {
+			_ = lease.Abort(ctx, nil)
 			return nil, errors.WithMessagef(err, "3 unable to import the template")
 		}
 	}
@@ -174,7 +623,7 @@ func createVirtualMachine(ctx context.Context, cisp types.OvfCreateImportSpecPar
 
 	moref := &info.Entity
 
-	vm := object.NewVirtualMachine(vSphereClient.Client, *moref)
+	vm := object.NewVirtualMachine(vSphere.Conn.Client, *moref)
 
 	return vm, nil
 }
@@ -212,6 +661,9 @@ func (t *tapeArchiveEntry) Close() error {
 type ova interface {
 	upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, ovaPath string) error
 	getImportSpec(ctx context.Context, ovaPath string, resourcePool mo.Reference, datastore mo.Reference, cisp types.OvfCreateImportSpecParams) (*types.OvfCreateImportSpecResult, error)
+	getEnvelope(ovaPath string) (*ovf.Envelope, error)
+	getSource(ovaPath string) (SourceInfo, error)
+	openOva(name string, ovaPath string) (io.ReadCloser, int64, error)
 }
 
 type handler struct {
@@ -246,6 +698,79 @@ func (h *handler) getImportSpec(ctx context.Context, ovaPath string, resourcePoo
 	return m.CreateImportSpec(ctx, string(o), resourcePool, datastore, cisp)
 }
 
+func (h *handler) getEnvelope(ovaPath string) (*ovf.Envelope, error) {
+	o, err := h.readOvf("*.ovf", ovaPath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to read OVF file from %s", ovaPath)
+	}
+	env, err := ovf.Unmarshal(bytes.NewReader(o))
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse OVF file from %s", ovaPath)
+	}
+	return env, nil
+}
+
+// getSource returns the digest of an OVA and, for a remote OVA, the ETag and Last-Modified headers of the download
+func (h *handler) getSource(ovaPath string) (SourceInfo, error) {
+	var info SourceInfo
+	var f io.ReadCloser
+	var err error
+	if isRemotePath(ovaPath) {
+		u, err := url.Parse(ovaPath)
+		if err != nil {
+			return info, errors.Wrapf(err, "Error parsing url %s", ovaPath)
+		}
+		res, err := h.downloader(u).DownloadRequest(context.TODO(), u, &soap.DefaultDownload)
+		if err != nil {
+			return info, errors.Wrapf(err, "error downloading %v", u)
+		}
+		if res.StatusCode != http.StatusOK {
+			_ = res.Body.Close()
+			return info, errors.New(fmt.Sprintf("error downloading %v: %v", u, res.Status))
+		}
+		info.ETag = res.Header.Get("ETag")
+		info.LastModified = res.Header.Get("Last-Modified")
+		f = res.Body
+	} else {
+		f, _, err = openLocal(ovaPath)
+		if err != nil {
+			return info, errors.WithMessagef(err, "error opening ova path %v", ovaPath)
+		}
+	}
+	defer f.Close()
+
+	info.Digest, err = ovaDigest(f)
+	return info, err
+}
+
+// ovaDigest returns the hex encoded sha256 of the OVF descriptor and, if there is one, the manifest of an OVA.
+// The manifest has the digests of the disks, so the disks themselves are not read. They come after the descriptor,
+// manifest and certificate in an OVA, reading stops at the first of them.
+func ovaDigest(r io.Reader) (string, error) {
+	hash := sha256.New()
+	tarReader := tar.NewReader(r)
+	for {
+		hdr, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", errors.Wrap(err, "error reading ova")
+		}
+		switch path.Ext(hdr.Name) {
+		case ".ovf", ".mf":
+			if _, err := io.Copy(hash, tarReader); err != nil {
+				return "", errors.Wrapf(err, "error reading %v from ova", hdr.Name)
+			}
+			continue
+		case ".cert":
+			continue
+		}
+		break
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func (h *handler) upload(ctx context.Context, lease *nfc.Lease, item nfc.FileItem, ovaPath string) error {
 	file := item.Path
 
@@ -316,11 +841,20 @@ func (h *handler) openRemote(link string) (io.ReadCloser, int64, error) {
 	if err != nil {
 		return nil, 0, errors.Wrapf(err, "Error parsing url %s", link)
 	}
-	rdr, num, err := h.client.Client.Download(context.TODO(), u, &soap.DefaultDownload)
+	rdr, num, err := h.downloader(u).Download(context.TODO(), u, &soap.DefaultDownload)
 	return rdr, num, errors.Wrapf(err, "error downloading %v", u)
 
 }
 
+// downloader returns the client that downloads remote OVAs. Without a vCenter connection, e.g. when only inspecting
+// an OVA, a plain soap client does the download.
+func (h *handler) downloader(u *url.URL) *soap.Client {
+	if h.client != nil {
+		return h.client.Client.Client
+	}
+	return soap.NewClient(u, true)
+}
+
 func removeNICs(ctx context.Context, vm *object.VirtualMachine) error {
 	vmProps, err := getProperties(ctx, vm)
 	if err != nil {
@@ -348,16 +882,7 @@ func removeNICs(ctx context.Context, vm *object.VirtualMachine) error {
 	vmConfigSpec := types.VirtualMachineConfigSpec{}
 	vmConfigSpec.DeviceChange = deviceConfigSpecs
 
-	task, err := vm.Reconfigure(ctx, vmConfigSpec)
-	if err != nil {
-		return errors.Wrapf(err, "could not reconfigure vm %s (%s)", vm.InventoryPath, vm.Reference())
-	}
-
-	if err := task.Wait(ctx); err != nil {
-		return errors.Wrapf(err, "failed waiting on vm reconfigure task for %s (%s)", vm.InventoryPath, vm.Reference())
-	}
-
-	return nil
+	return reconfigure(ctx, vm, vmConfigSpec)
 }
 
 func sliceDedup(list []string) []string {
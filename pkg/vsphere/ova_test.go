@@ -3,7 +3,19 @@
 package vsphere
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
+	"text/template"
+
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
 func TestDeployOVATemplate(t *testing.T) {
@@ -29,3 +41,330 @@ func TestDeployOVATemplate(t *testing.T) {
 		t.Fatalf(err.Error())
 	}
 }
+
+type testOVF struct {
+	Name            string
+	OSType          string
+	HardwareVersion string
+	EULA            string
+	Product         string
+	Version         string
+}
+
+var testOVFTemplate = template.Must(template.New("ovf").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<Envelope xmlns="http://schemas.dmtf.org/ovf/envelope/1" xmlns:ovf="http://schemas.dmtf.org/ovf/envelope/1" xmlns:vmw="http://www.vmware.com/schema/ovf" xmlns:rasd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_ResourceAllocationSettingData" xmlns:vssd="http://schemas.dmtf.org/wbem/wscim/1/cim-schema/2/CIM_VirtualSystemSettingData">
+  <References>
+    <File ovf:href="disk-0.vmdk" ovf:id="file1" ovf:size="512"/>
+  </References>
+  <DiskSection>
+    <Info>Virtual disk information</Info>
+    <Disk ovf:capacity="1" ovf:capacityAllocationUnits="byte * 2^30" ovf:diskId="vmdisk1" ovf:fileRef="file1" ovf:format="http://www.vmware.com/interfaces/specifications/vmdk.html#streamOptimized" ovf:populatedSize="512"/>
+  </DiskSection>
+  <NetworkSection>
+    <Info>The list of logical networks</Info>
+    <Network ovf:name="nic0">
+      <Description>The nic0 network</Description>
+    </Network>
+  </NetworkSection>
+  <VirtualSystem ovf:id="{{.Name}}">
+    <Info>A virtual machine</Info>
+    <Name>{{.Name}}</Name>
+    <OperatingSystemSection ovf:id="101"{{if .OSType}} vmw:osType="{{.OSType}}"{{end}}>
+      <Info>The kind of installed guest operating system</Info>
+    </OperatingSystemSection>
+    {{if .Product}}<ProductSection>
+      <Info>Information about the installed software</Info>
+      <Product>{{.Product}}</Product>
+      <Version>{{.Version}}</Version>
+    </ProductSection>{{end}}
+    {{if .EULA}}<EulaSection>
+      <Info>End User License Agreement</Info>
+      <License>{{.EULA}}</License>
+    </EulaSection>{{end}}
+    <VirtualHardwareSection>
+      <Info>Virtual hardware requirements</Info>
+      <System>
+        <vssd:ElementName>Virtual Hardware Family</vssd:ElementName>
+        <vssd:InstanceID>0</vssd:InstanceID>
+        <vssd:VirtualSystemIdentifier>{{.Name}}</vssd:VirtualSystemIdentifier>
+        {{if .HardwareVersion}}<vssd:VirtualSystemType>{{.HardwareVersion}}</vssd:VirtualSystemType>{{end}}
+      </System>
+      <Item>
+        <rasd:AllocationUnits>hertz * 10^6</rasd:AllocationUnits>
+        <rasd:ElementName>1 virtual CPU(s)</rasd:ElementName>
+        <rasd:InstanceID>1</rasd:InstanceID>
+        <rasd:ResourceType>3</rasd:ResourceType>
+        <rasd:VirtualQuantity>1</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:AllocationUnits>byte * 2^20</rasd:AllocationUnits>
+        <rasd:ElementName>32MB of memory</rasd:ElementName>
+        <rasd:InstanceID>2</rasd:InstanceID>
+        <rasd:ResourceType>4</rasd:ResourceType>
+        <rasd:VirtualQuantity>32</rasd:VirtualQuantity>
+      </Item>
+      <Item>
+        <rasd:Address>0</rasd:Address>
+        <rasd:ElementName>SCSI controller 0</rasd:ElementName>
+        <rasd:InstanceID>3</rasd:InstanceID>
+        <rasd:ResourceSubType>lsilogic</rasd:ResourceSubType>
+        <rasd:ResourceType>6</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>0</rasd:AddressOnParent>
+        <rasd:ElementName>Hard disk 1</rasd:ElementName>
+        <rasd:HostResource>ovf:/disk/vmdisk1</rasd:HostResource>
+        <rasd:InstanceID>4</rasd:InstanceID>
+        <rasd:Parent>3</rasd:Parent>
+        <rasd:ResourceType>17</rasd:ResourceType>
+      </Item>
+      <Item>
+        <rasd:AddressOnParent>7</rasd:AddressOnParent>
+        <rasd:AutomaticAllocation>true</rasd:AutomaticAllocation>
+        <rasd:Connection>nic0</rasd:Connection>
+        <rasd:ElementName>Network adapter 1</rasd:ElementName>
+        <rasd:InstanceID>5</rasd:InstanceID>
+        <rasd:ResourceSubType>E1000</rasd:ResourceSubType>
+        <rasd:ResourceType>10</rasd:ResourceType>
+      </Item>
+    </VirtualHardwareSection>
+  </VirtualSystem>
+</Envelope>
+`))
+
+// newTestOVA writes a tiny OVA, that the simulator can import, to a temporary directory
+func newTestOVA(t *testing.T, o testOVF) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "ovaimporter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	var descriptor bytes.Buffer
+	if err := testOVFTemplate.Execute(&descriptor, o); err != nil {
+		t.Fatal(err)
+	}
+	ovaPath := filepath.Join(dir, o.Name+".ova")
+	f, err := os.Create(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	tw := tar.NewWriter(f)
+	files := []struct {
+		name string
+		body []byte
+	}{
+		{o.Name + ".ovf", descriptor.Bytes()},
+		{"disk-0.vmdk", make([]byte, 512)},
+	}
+	for _, file := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: file.name, Mode: 0644, Size: int64(len(file.body))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(file.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return ovaPath
+}
+
+// importSpecRecorder records the import specs of ImportVApp calls, the simulator doesn't keep all of a spec on the VM
+// and a failed import leaves nothing to look at
+type importSpecRecorder struct {
+	soap.RoundTripper
+	specs []types.BaseImportSpec
+}
+
+func (r *importSpecRecorder) RoundTrip(ctx context.Context, req, res soap.HasFault) error {
+	if body, ok := req.(*methods.ImportVAppBody); ok {
+		r.specs = append(r.specs, body.Req.Spec)
+	}
+	return r.RoundTripper.RoundTrip(ctx, req, res)
+}
+
+// useTestTargets points the simulator session at the default import targets
+func useTestTargets(t *testing.T) {
+	t.Helper()
+	var err error
+	sim.conn.Network, err = sim.conn.GetNetworkOrDefault("/DC0/network/VM Network")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.ResourcePool, err = sim.conn.GetResourcePoolOrDefault("/DC0/host/DC0_H0/Resources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Datastore, err = sim.conn.GetDatastoreOrDefault("/DC0/datastore/LocalDS_0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Folder, err = sim.conn.GetFolderOrDefault("/DC0/vm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Cluster = nil
+	sim.conn.Host = nil
+	sim.conn.Options = DeployOptions{}
+}
+
+func TestDeployOVATemplateLocal(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "local-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AlreadyExists {
+		t.Fatal("expected a new template to be created")
+	}
+	if info.TemplateName != "local-tiny" {
+		t.Fatalf("expected: local-tiny, actual: %v", info.TemplateName)
+	}
+
+	info, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.AlreadyExists {
+		t.Fatal("expected the template to already exist")
+	}
+	if info.Kind != KindTemplate {
+		t.Fatalf("expected: %v, actual: %v", KindTemplate, info.Kind)
+	}
+}
+
+func TestDeployOVATemplateMode(t *testing.T) {
+	tests := map[string]struct {
+		mode  string
+		kind  string
+		power types.VirtualMachinePowerState
+	}{
+		"vm":         {ModeVM, KindVM, types.VirtualMachinePowerStatePoweredOff},
+		"vm-poweron": {ModeVMPowerOn, KindVM, types.VirtualMachinePowerStatePoweredOn},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useTestTargets(t)
+			sim.conn.Options.Mode = tc.mode
+			ovaPath := newTestOVA(t, testOVF{Name: "mode-" + name, OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+			info, err := sim.conn.DeployOVATemplate(ovaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Kind != tc.kind {
+				t.Fatalf("expected: %v, actual: %v", tc.kind, info.Kind)
+			}
+			power, err := info.VMObject.PowerState(sim.conn.Ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if power != tc.power {
+				t.Fatalf("expected: %v, actual: %v", tc.power, power)
+			}
+
+			info, err = sim.conn.DeployOVATemplate(ovaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.AlreadyExists || info.Kind != tc.kind {
+				t.Fatalf("expected an existing %v, actual: %+v", tc.kind, info)
+			}
+
+			sim.conn.Options.Mode = ModeTemplate
+			_, err = sim.conn.DeployOVATemplate(ovaPath)
+			errMsg := fmt.Sprintf("mode-%v already exists as a vm, not a template", name)
+			if err == nil || err.Error() != errMsg {
+				t.Fatalf("expected: %v, actual: %v", errMsg, err)
+			}
+		})
+	}
+}
+
+func TestDeployOVATemplateUnknownMode(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "mode-unknown", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+	errMsg := "unknown mode appliance, must be template, vm or vm-poweron"
+
+	sim.conn.Options.Mode = "appliance"
+	_, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil || err.Error() != errMsg {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err)
+	}
+
+	// the mode is checked before an existing template is compared with it
+	sim.conn.Options.Mode = ""
+	if _, err := sim.conn.DeployOVATemplate(ovaPath); err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Options.Mode = "appliance"
+	_, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil || err.Error() != errMsg {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err)
+	}
+}
+
+func TestDeployOVATemplateESXi(t *testing.T) {
+	useTestTargets(t)
+	// the client only looks at the API type to detect a standalone host
+	about := &sim.conn.Conn.ServiceContent.About
+	apiType := about.ApiType
+	about.ApiType = "HostAgent"
+	t.Cleanup(func() { about.ApiType = apiType })
+	sim.conn.Options.Snapshot = "imported"
+	ovaPath := newTestOVA(t, testOVF{Name: "esxi-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Kind != KindVM {
+		t.Fatalf("expected: %v, actual: %v", KindVM, info.Kind)
+	}
+	isTemplate, err := info.VMObject.IsTemplate(sim.conn.Ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isTemplate {
+		t.Fatal("expected a VM, not a template")
+	}
+	if _, err := info.VMObject.FindSnapshot(sim.conn.Ctx, "imported"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeployOVATemplateEULA(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "eula-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13", EULA: "you agree to everything"})
+
+	_, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil {
+		t.Fatal("expected the import to be refused without accepting the EULA")
+	}
+	if err.Error() != "eula-tiny has a EULA that must be accepted before it can be imported" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sim.conn.Options.AcceptEULA = true
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedHash := eulaHash([]string{"you agree to everything"})
+	if info.EULAHash != expectedHash {
+		t.Fatalf("expected: %v, actual: %v", expectedHash, info.EULAHash)
+	}
+	props, err := getProperties(sim.conn.Ctx, info.VMObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if getMetadata(props.Config.Annotation)[MetadataEULAHash] != expectedHash {
+		t.Fatalf("expected annotation to contain the EULA hash, actual: %q", props.Config.Annotation)
+	}
+}
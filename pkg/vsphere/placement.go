@@ -0,0 +1,132 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// HostPlacement describes the host of a cluster an OVA is imported to
+type HostPlacement struct {
+	Cluster string `json:"cluster"`
+	Host    string `json:"host"`
+	DRS     bool   `json:"drs"`
+	Reason  string `json:"reason"`
+}
+
+// ValidateComputeTargets checks that the session's host belongs to the session's cluster and to the cluster or
+// standalone host that owns the session's resource pool, before anything is uploaded
+func (s *Session) ValidateComputeTargets() error {
+	if s.Host == nil {
+		return nil
+	}
+	ctx := s.Ctx
+	var host mo.HostSystem
+	if err := s.Host.Properties(ctx, s.Host.Reference(), []string{"name", "parent"}, &host); err != nil {
+		return errors.Wrapf(err, "unable to get the compute resource of host %v", s.Host.InventoryPath)
+	}
+	if host.Parent == nil {
+		return errors.New(fmt.Sprintf("host %v is not in a compute resource", host.Name))
+	}
+	if s.Cluster != nil && s.Cluster.Reference() != *host.Parent {
+		return errors.New(fmt.Sprintf("host %v is not in cluster %v", host.Name, s.Cluster.InventoryPath))
+	}
+	if s.ResourcePool == nil {
+		return nil
+	}
+	owner, err := s.ResourcePool.Owner(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to find owner of resource pool %v", s.ResourcePool.InventoryPath)
+	}
+	if owner.Reference() != *host.Parent {
+		return errors.New(fmt.Sprintf("host %v is not in %v, the owner of resource pool %v", host.Name, owner.Reference().Value, s.ResourcePool.InventoryPath))
+	}
+	return nil
+}
+
+// PlaceOnCluster picks the host of a cluster to import an OVA to. The recommendations of ClusterComputeResource.PlaceVm
+// are used in order, the first host that can reach the session's datastore and network is chosen.
+func (s *Session) PlaceOnCluster(cluster *object.ClusterComputeResource, ovaPath string) (*object.HostSystem, HostPlacement, error) {
+	placement := HostPlacement{Cluster: cluster.Name()}
+	if s.Datastore == nil {
+		return nil, placement, errors.New("no datastore specified in connection session")
+	}
+
+	importSpec, err := s.placementImportSpec(ovaPath, s.Datastore)
+	if err != nil {
+		return nil, placement, err
+	}
+	spec := types.PlacementSpec{
+		PlacementType: string(types.PlacementSpecPlacementTypeCreate),
+		ConfigSpec:    &importSpec.ConfigSpec,
+	}
+	result, err := cluster.PlaceVm(s.Ctx, spec)
+	if err != nil {
+		return nil, placement, errors.Wrapf(err, "unable to get DRS recommendations for cluster %v", cluster.Name())
+	}
+
+	var refs []types.ManagedObjectReference
+	for _, rec := range result.Recommendations {
+		for _, action := range rec.Action {
+			if pa, ok := action.(*types.PlacementAction); ok && pa.TargetHost != nil {
+				refs = append(refs, *pa.TargetHost)
+			}
+		}
+	}
+	hosts, err := s.hostProperties(s.Ctx, refs)
+	if err != nil {
+		return nil, placement, err
+	}
+	for _, rec := range result.Recommendations {
+		for _, action := range rec.Action {
+			pa, ok := action.(*types.PlacementAction)
+			if !ok || pa.TargetHost == nil {
+				continue
+			}
+			for _, host := range hosts {
+				if host.Reference() != *pa.TargetHost || len(s.hostAccessProblems(host)) > 0 {
+					continue
+				}
+				placement.Host = host.Name
+				placement.DRS = true
+				placement.Reason = rec.ReasonText
+				return object.NewHostSystem(s.Conn.Client, host.Reference()), placement, nil
+			}
+		}
+	}
+	return nil, placement, errors.New(fmt.Sprintf("DRS has no recommendation for cluster %v with access to datastore %v", cluster.Name(), s.Datastore.Name()))
+}
+
+// checkHostAccess fails the preflight when the session's host can't reach the datastore or network of the import
+func (s *Session) checkHostAccess(ctx context.Context, result *PreflightResult) error {
+	if s.Host == nil {
+		return nil
+	}
+	hosts, err := s.hostProperties(ctx, []types.ManagedObjectReference{s.Host.Reference()})
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		for _, problem := range s.hostAccessProblems(host) {
+			result.fail("%v", problem)
+		}
+	}
+	return nil
+}
+
+// hostAccessProblems lists why a host can't be used with the session's datastore and network
+func (s *Session) hostAccessProblems(host mo.HostSystem) []string {
+	var problems []string
+	if s.Datastore != nil && !containsRef(host.Datastore, s.Datastore.Reference()) {
+		problems = append(problems, fmt.Sprintf("host %v has no access to datastore %v", host.Name, s.Datastore.Name()))
+	}
+	if s.Network != nil && !containsRef(host.Network, s.Network.Reference()) {
+		problems = append(problems, fmt.Sprintf("host %v has no access to network %v", host.Name, s.Network.Reference().Value))
+	}
+	return problems
+}
@@ -0,0 +1,119 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"strings"
+	"testing"
+)
+
+// useTestCluster targets the cluster DC0_C0 instead of the standalone host DC0_H0
+func useTestCluster(t *testing.T) {
+	t.Helper()
+	useTestTargets(t)
+	var err error
+	sim.conn.Cluster, err = sim.conn.GetClusterOrDefault("/DC0/host/DC0_C0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.ResourcePool, err = sim.conn.Cluster.ResourcePool(sim.conn.Ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlaceOnCluster(t *testing.T) {
+	useTestCluster(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "cluster-drs", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	host, placement, err := sim.conn.PlaceOnCluster(sim.conn.Cluster, ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !placement.DRS || placement.Cluster != "DC0_C0" {
+		t.Fatalf("expected a DRS placement on DC0_C0, actual: %+v", placement)
+	}
+	if !strings.HasPrefix(placement.Host, "DC0_C0_H") {
+		t.Fatalf("expected a host of DC0_C0, actual: %v", placement.Host)
+	}
+
+	sim.conn.Host = host
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm, err := sim.conn.GetVM(info.TemplateName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vmHost, err := vm.HostSystem(sim.conn.Ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vmHost.Reference() != host.Reference() {
+		t.Fatalf("expected: %v, actual: %v", host.Reference(), vmHost.Reference())
+	}
+}
+
+func TestPreflightHostWithoutDatastore(t *testing.T) {
+	useTestCluster(t)
+	createTestDatastore(t, "DC0_C0_H1", "HostOnlyDS")
+	ovaPath := newTestOVA(t, testOVF{Name: "host-access", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+	var err error
+	sim.conn.Datastore, err = sim.conn.GetDatastoreOrDefault("HostOnlyDS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Host, err = sim.conn.GetHostOrDefault("/DC0/host/DC0_C0/DC0_C0_H0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sim.conn.Preflight(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errMsg := "host DC0_C0_H0 has no access to datastore HostOnlyDS"
+	if len(result.Errors) != 1 || result.Errors[0] != errMsg {
+		t.Fatalf("expected: %v, actual: %v", errMsg, result.Errors)
+	}
+}
+
+func TestValidateComputeTargets(t *testing.T) {
+	tests := map[string]struct {
+		cluster      string
+		host         string
+		resourcePool string
+		err          bool
+	}{
+		"no host":                          {cluster: "/DC0/host/DC0_C0", resourcePool: "/DC0/host/DC0_C0/Resources"},
+		"host of the cluster":              {cluster: "/DC0/host/DC0_C0", host: "/DC0/host/DC0_C0/DC0_C0_H0", resourcePool: "/DC0/host/DC0_C0/Resources"},
+		"host of another cluster":          {cluster: "/DC0/host/DC0_C0", host: "/DC0/host/DC0_H0/DC0_H0", resourcePool: "/DC0/host/DC0_C0/Resources", err: true},
+		"host of the resource pool":        {host: "/DC0/host/DC0_H0/DC0_H0", resourcePool: "/DC0/host/DC0_H0/Resources"},
+		"host of another resource pool":    {host: "/DC0/host/DC0_C0/DC0_C0_H0", resourcePool: "/DC0/host/DC0_H0/Resources", err: true},
+		"cluster of another resource pool": {cluster: "/DC0/host/DC0_C0", host: "/DC0/host/DC0_C0/DC0_C0_H0", resourcePool: "/DC0/host/DC0_H0/Resources", err: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useTestTargets(t)
+			var err error
+			if tc.cluster != "" {
+				if sim.conn.Cluster, err = sim.conn.GetClusterOrDefault(tc.cluster); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tc.host != "" {
+				if sim.conn.Host, err = sim.conn.GetHostOrDefault(tc.host); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if sim.conn.ResourcePool, err = sim.conn.GetResourcePoolOrDefault(tc.resourcePool); err != nil {
+				t.Fatal(err)
+			}
+			err = sim.conn.ValidateComputeTargets()
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %v, actual: %v", tc.err, err)
+			}
+		})
+	}
+}
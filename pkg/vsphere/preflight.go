@@ -0,0 +1,207 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/ovf"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// PreflightResult is the outcome of the checks run before an OVA is uploaded
+type PreflightResult struct {
+	HardwareVersion   string   `json:"hardwareVersion,omitempty"`
+	SupportedHardware []string `json:"supportedHardware,omitempty"`
+	GuestID           string   `json:"guestId,omitempty"`
+	RequiredSpaceGB   float64  `json:"requiredSpaceGB,omitempty"`
+	FreeSpaceGB       float64  `json:"freeSpaceGB,omitempty"`
+	ReservedSpaceGB   float64  `json:"reservedSpaceGB,omitempty"`
+	Warnings          []string `json:"warnings,omitempty"`
+	Errors            []string `json:"errors,omitempty"`
+}
+
+// Err returns an error describing all failed checks, or nil if there were none
+func (p PreflightResult) Err() error {
+	if len(p.Errors) == 0 {
+		return nil
+	}
+	return errors.New(fmt.Sprintf("preflight failed: %v", strings.Join(p.Errors, "; ")))
+}
+
+func (p *PreflightResult) warn(format string, a ...interface{}) {
+	p.Warnings = append(p.Warnings, fmt.Sprintf(format, a...))
+}
+
+func (p *PreflightResult) fail(format string, a ...interface{}) {
+	p.Errors = append(p.Errors, fmt.Sprintf(format, a...))
+}
+
+// Preflight checks that the compute resource owning the session's resource pool can run the VM described by an OVA,
+// that the session's host can reach the datastore and network, that the datastore is compatible with the storage policy
+// and that the disks fit on the datastore
+func (s *Session) Preflight(ovaPath string) (PreflightResult, error) {
+	var result PreflightResult
+	env, err := s.readEnvelope(ovaPath)
+	if err != nil {
+		return result, err
+	}
+	err = s.preflight(s.Ctx, env, &result)
+	return result, err
+}
+
+func (s *Session) preflight(ctx context.Context, env *ovf.Envelope, result *PreflightResult) error {
+	if err := s.checkCompatibility(ctx, env, result); err != nil {
+		return err
+	}
+	if err := s.checkHostAccess(ctx, result); err != nil {
+		return err
+	}
+	return s.checkCapacity(env, result)
+}
+
+// checkCapacity compares the size of the OVF's disks, for the chosen provisioning, with the free space
+// of the datastore minus the reserve of MinFreePercent
+func (s *Session) checkCapacity(env *ovf.Envelope, result *PreflightResult) error {
+	required, err := ovfRequiredSpace(env, s.Options.thinProvisioned())
+	if err != nil {
+		return err
+	}
+	capacity, free, err := s.DatastoreCapacity()
+	if err != nil {
+		return err
+	}
+	result.RequiredSpaceGB = float64(required) / (1 << 30)
+	result.FreeSpaceGB = free
+	result.ReservedSpaceGB = capacity * s.Options.MinFreePercent / 100
+	if available := free - result.ReservedSpaceGB; result.RequiredSpaceGB > available {
+		result.fail("datastore %v has %.2fGB available (%.2fGB free, %.2fGB reserved) but %.2fGB is required",
+			s.Datastore.Name(), available, free, result.ReservedSpaceGB, result.RequiredSpaceGB)
+	}
+	return nil
+}
+
+// checkCompatibility compares the OVF's VirtualSystemType and OperatingSystemSection
+// with what the EnvironmentBrowser of the target compute resource supports
+func (s *Session) checkCompatibility(ctx context.Context, env *ovf.Envelope, result *PreflightResult) error {
+	if s.ResourcePool == nil {
+		return errors.New("no resource pool specified in connection session")
+	}
+	browser, err := s.environmentBrowser(ctx)
+	if err != nil {
+		return err
+	}
+
+	descriptors, err := methods.QueryConfigOptionDescriptor(ctx, s.Conn.Client, &types.QueryConfigOptionDescriptor{This: browser})
+	if err != nil {
+		return errors.Wrap(err, "unable to query supported hardware versions")
+	}
+	supported := make(map[string]bool)
+	for _, d := range descriptors.Returnval {
+		if d.CreateSupported != nil && !*d.CreateSupported {
+			continue
+		}
+		supported[d.Key] = true
+		result.SupportedHardware = append(result.SupportedHardware, d.Key)
+	}
+
+	requested := ovfHardwareVersions(env)
+	switch {
+	case len(requested) == 0:
+		result.warn("OVF does not specify a VMware hardware version (VirtualSystemType)")
+	default:
+		for _, v := range requested {
+			if supported[v] {
+				result.HardwareVersion = v
+			}
+		}
+		if result.HardwareVersion == "" {
+			result.fail("hardware version %v is not supported by the target compute resource (supported: %v)",
+				strings.Join(requested, ","), strings.Join(result.SupportedHardware, ","))
+		}
+	}
+
+	result.GuestID = ovfGuestID(env)
+	if result.GuestID == "" {
+		result.warn("OVF does not specify a guest OS type (OperatingSystemSection osType)")
+		return nil
+	}
+	spec := &types.EnvironmentBrowserConfigOptionQuerySpec{
+		Key:     result.HardwareVersion,
+		GuestId: []string{result.GuestID},
+	}
+	option, err := methods.QueryConfigOptionEx(ctx, s.Conn.Client, &types.QueryConfigOptionEx{This: browser, Spec: spec})
+	if err != nil {
+		return errors.Wrap(err, "unable to query supported guest operating systems")
+	}
+	if option.Returnval == nil {
+		result.warn("unable to determine if guest OS %v is supported", result.GuestID)
+		return nil
+	}
+	for _, desc := range option.Returnval.GuestOSDescriptor {
+		if desc.Id != result.GuestID {
+			continue
+		}
+		switch desc.SupportLevel {
+		case string(types.GuestOsDescriptorSupportLevelUnsupported), string(types.GuestOsDescriptorSupportLevelDeprecated), string(types.GuestOsDescriptorSupportLevelTerminated):
+			result.warn("guest OS %v has support level %v on the target compute resource", result.GuestID, desc.SupportLevel)
+		}
+		return nil
+	}
+	result.warn("guest OS %v is not known to the target compute resource", result.GuestID)
+	return nil
+}
+
+// environmentBrowser returns the EnvironmentBrowser of the cluster or host that owns the session's resource pool
+func (s *Session) environmentBrowser(ctx context.Context) (types.ManagedObjectReference, error) {
+	var cr mo.ComputeResource
+	owner, err := s.ResourcePool.Owner(ctx)
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "unable to find owner of resource pool %v", s.ResourcePool.InventoryPath)
+	}
+	pc := s.Conn.PropertyCollector()
+	err = pc.RetrieveOne(ctx, owner.Reference(), []string{"environmentBrowser"}, &cr)
+	if err != nil {
+		return types.ManagedObjectReference{}, errors.Wrapf(err, "unable to retrieve environment browser of %v", owner.Reference())
+	}
+	if cr.EnvironmentBrowser == nil {
+		return types.ManagedObjectReference{}, errors.New(fmt.Sprintf("compute resource %v has no environment browser", owner.Reference()))
+	}
+	return *cr.EnvironmentBrowser, nil
+}
+
+// ovfHardwareVersions returns the vmx-NN hardware versions listed in the OVF's VirtualSystemType
+func ovfHardwareVersions(env *ovf.Envelope) []string {
+	var versions []string
+	if env.VirtualSystem == nil {
+		return versions
+	}
+	for _, hw := range env.VirtualSystem.VirtualHardware {
+		if hw.System == nil || hw.System.VirtualSystemType == nil {
+			continue
+		}
+		for _, v := range strings.FieldsFunc(*hw.System.VirtualSystemType, func(r rune) bool { return r == ' ' || r == ',' }) {
+			if strings.HasPrefix(v, "vmx-") {
+				versions = append(versions, v)
+			}
+		}
+	}
+	return versions
+}
+
+// ovfGuestID returns the vSphere guest id from the OVF's OperatingSystemSection
+func ovfGuestID(env *ovf.Envelope) string {
+	if env.VirtualSystem == nil {
+		return ""
+	}
+	for _, os := range env.VirtualSystem.OperatingSystem {
+		if os.OSType != nil {
+			return *os.OSType
+		}
+	}
+	return ""
+}
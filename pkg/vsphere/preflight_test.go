@@ -0,0 +1,108 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPreflight(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "preflight-ok", OSType: "otherLinux64Guest", HardwareVersion: "vmx-10 vmx-13"})
+
+	result, err := sim.conn.Preflight(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Err() != nil {
+		t.Fatalf("unexpected preflight errors: %v", result.Errors)
+	}
+	if result.HardwareVersion != "vmx-13" {
+		t.Fatalf("expected: vmx-13, actual: %v", result.HardwareVersion)
+	}
+	if len(result.Warnings) != 0 {
+		t.Fatalf("unexpected preflight warnings: %v", result.Warnings)
+	}
+}
+
+func TestPreflightUnsupportedHardware(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "preflight-hw", OSType: "otherLinux64Guest", HardwareVersion: "vmx-99"})
+
+	result, err := sim.conn.Preflight(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = result.Err()
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if !strings.Contains(err.Error(), "hardware version vmx-99 is not supported") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil {
+		t.Fatal("expected the import to be refused")
+	}
+}
+
+func TestPreflightWarnings(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "preflight-warn", OSType: "madeUpGuest"})
+
+	result, err := sim.conn.Preflight(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Err() != nil {
+		t.Fatalf("unexpected preflight errors: %v", result.Errors)
+	}
+	if len(result.Warnings) != 2 {
+		t.Fatalf("expected 2 warnings, actual: %v", result.Warnings)
+	}
+}
+
+func TestPreflightCapacity(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "preflight-capacity", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	result, err := sim.conn.Preflight(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequiredSpaceGB != float64(512)/(1<<30) {
+		t.Fatalf("expected the populated size to be required, actual: %vGB", result.RequiredSpaceGB)
+	}
+
+	sim.conn.Options.DiskProvisioning = "thick"
+	result, err = sim.conn.Preflight(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequiredSpaceGB != 1 {
+		t.Fatalf("expected the disk capacity to be required, actual: %vGB", result.RequiredSpaceGB)
+	}
+}
+
+func TestPreflightCapacityReserve(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.MinFreePercent = 100
+	ovaPath := newTestOVA(t, testOVF{Name: "preflight-reserve", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	result, err := sim.conn.Preflight(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = result.Err()
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if !strings.Contains(err.Error(), "datastore LocalDS_0 has") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.ReservedSpaceGB == 0 {
+		t.Fatal("expected the reserved space to be reported")
+	}
+}
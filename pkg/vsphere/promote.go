@@ -0,0 +1,312 @@
+package vsphere
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// PromoteTag marks a promotion location that is a category/tag instead of a folder
+const PromoteTag = "tag:"
+
+// Actions taken by Promote
+const (
+	PromoteMoved  = "moved"
+	PromoteCloned = "cloned"
+)
+
+// metadataPromotion prefixes the numbered promotion history entries in the annotation of a template
+const metadataPromotion = "promotion."
+
+// Promotion is an entry of the promotion history recorded on a template
+type Promotion struct {
+	At   string `json:"at"`
+	By   string `json:"by"`
+	From string `json:"from"`
+	To   string `json:"to"`
+	// VCenter the template was promoted from, when it was cloned to another vCenter
+	VCenter string `json:"vcenter,omitempty"`
+}
+
+// PromoteOptions selects the template Promote promotes and where to
+type PromoteOptions struct {
+	Name string
+	// From and To are a folder or tag:<category/tag>
+	From string
+	To   string
+	// Clone keeps the template in From and promotes a clone of it
+	Clone bool
+	// Target is a session on another vCenter the template is cloned to, the folder, resource pool and
+	// datastore of the clone are the ones of the target session
+	Target *Session
+}
+
+// PromoteResult describes the promoted template and its promotion history, oldest first
+type PromoteResult struct {
+	Name     string       `json:"name"`
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Action   string       `json:"action"`
+	Template TemplateInfo `json:"template"`
+	History  []Promotion  `json:"history"`
+}
+
+// promoteLocation splits a promotion location into a folder or a category/tag
+func promoteLocation(location string) (folder string, tag string) {
+	if strings.HasPrefix(location, PromoteTag) {
+		return "", strings.TrimPrefix(location, PromoteTag)
+	}
+	return location, ""
+}
+
+// Promote moves or clones a template from a folder or tag to another one, optionally on another vCenter.
+// The tags of the template follow the promotion and the promotion is appended to the history in its metadata.
+func (s *Session) Promote(opts PromoteOptions) (PromoteResult, error) {
+	result := PromoteResult{Name: opts.Name, From: opts.From, To: opts.To, History: []Promotion{}}
+	ctx := s.Ctx
+	switch {
+	case opts.Name == "":
+		return result, errors.New("the name of the template must be given")
+	case opts.From == "" || opts.To == "":
+		return result, errors.New("the location to promote from and to must be given")
+	case opts.From == opts.To && opts.Target == nil:
+		return result, errors.New(fmt.Sprintf("can't promote %v from %v to itself", opts.Name, opts.From))
+	}
+	target := s
+	if opts.Target != nil {
+		target = opts.Target
+		opts.Clone = true
+	}
+
+	vm, props, err := s.promotionSource(opts)
+	if err != nil {
+		return result, err
+	}
+	toFolderName, toTag := promoteLocation(opts.To)
+	var toCategory []string
+	if toTag != "" {
+		if toCategory = strings.SplitN(toTag, "/", 2); len(toCategory) != 2 || toCategory[0] == "" || toCategory[1] == "" {
+			return result, errors.New(fmt.Sprintf("tag %q is not of the form category/tag", toTag))
+		}
+	}
+	var toFolder *object.Folder
+	switch {
+	case toFolderName != "":
+		if toFolder, err = target.GetFolderOrDefault(toFolderName); err != nil {
+			return result, err
+		}
+		if _, err := target.GetVM(path.Join(toFolder.InventoryPath, props.Name)); err == nil {
+			return result, errors.New(fmt.Sprintf("%v already exists in %v", props.Name, toFolder.InventoryPath))
+		}
+	case opts.Target != nil:
+		if toFolder = target.Folder; toFolder == nil {
+			if toFolder, err = target.GetFolderOrDefault(""); err != nil {
+				return result, err
+			}
+		}
+	case opts.Clone:
+		return result, errors.New("a template can only be cloned to a folder")
+	}
+
+	promotion := Promotion{
+		At:   time.Now().UTC().Format(time.RFC3339),
+		By:   s.user.Username(),
+		From: opts.From,
+		To:   opts.To,
+	}
+	if opts.Target != nil {
+		promotion.VCenter = s.Conn.URL().Host
+	}
+	history := promotionHistory(getMetadata(props.Config.Annotation))
+	history = append(history, promotion)
+	encoded, err := json.Marshal(promotion)
+	if err != nil {
+		return result, errors.Wrap(err, "unable to encode the promotion")
+	}
+	annotation := setMetadata(props.Config.Annotation, map[string]string{metadataPromotion + strconv.Itoa(len(history)): string(encoded)})
+
+	promoted := vm
+	if opts.Clone {
+		result.Action = PromoteCloned
+		if promoted, err = s.clonePromotion(ctx, vm, props.Name, target, toFolder); err != nil {
+			return result, err
+		}
+	} else {
+		result.Action = PromoteMoved
+		if toFolder != nil {
+			if err := moveVM(ctx, vm, toFolder); err != nil {
+				return result, err
+			}
+		}
+	}
+	if err := reconfigureAnnotation(ctx, promoted, annotation); err != nil {
+		return result, err
+	}
+
+	if fromFolder, fromTag := promoteLocation(opts.From); fromFolder == "" && !opts.Clone {
+		if err := s.detachTag(ctx, vm, fromTag); err != nil {
+			return result, err
+		}
+	}
+	if toTag != "" {
+		if err := target.attachTag(ctx, promoted, toCategory[0], toCategory[1]); err != nil {
+			return result, err
+		}
+	}
+
+	var promotedProps mo.VirtualMachine
+	if err := promoted.Properties(ctx, promoted.Reference(), templateProperties, &promotedProps); err != nil {
+		return result, errors.Wrapf(err, "unable to get the properties of %v", promoted.Reference())
+	}
+	result.Template = templateInfo(promotedProps, promoted.InventoryPath)
+	tags, err := target.attachedTags(ctx, []mo.VirtualMachine{promotedProps})
+	if err != nil {
+		return result, err
+	}
+	result.Template.Tags = append(result.Template.Tags, tags[promotedProps.Self]...)
+	result.History = history
+	return result, nil
+}
+
+// promotionSource finds the template to promote in the folder or with the tag it's promoted from
+func (s *Session) promotionSource(opts PromoteOptions) (*object.VirtualMachine, *mo.VirtualMachine, error) {
+	fromFolder, fromTag := promoteLocation(opts.From)
+	if fromFolder != "" {
+		folder, err := s.GetFolderOrDefault(fromFolder)
+		if err != nil {
+			return nil, nil, err
+		}
+		return s.getTemplate(path.Join(folder.InventoryPath, opts.Name))
+	}
+	vm, props, err := s.getTemplate(opts.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	tagged, err := s.taggedObjects(fromTag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !tagged[vm.Reference()] {
+		return nil, nil, errors.New(fmt.Sprintf("%v is not tagged with %v", opts.Name, fromTag))
+	}
+	return vm, props, nil
+}
+
+// clonePromotion clones a template to a folder of the target session, which is on another vCenter when it isn't s
+func (s *Session) clonePromotion(ctx context.Context, vm *object.VirtualMachine, name string, target *Session, folder *object.Folder) (*object.VirtualMachine, error) {
+	if target.ResourcePool == nil {
+		return nil, errors.New("a resource pool is needed to clone the template")
+	}
+	pool := target.ResourcePool.Reference()
+	spec := types.VirtualMachineCloneSpec{
+		Location: types.VirtualMachineRelocateSpec{Pool: &pool, Folder: types.NewReference(folder.Reference())},
+		Template: true,
+	}
+	if target.Datastore != nil {
+		spec.Location.Datastore = types.NewReference(target.Datastore.Reference())
+	}
+	if target != s {
+		service, err := target.serviceLocator()
+		if err != nil {
+			return nil, err
+		}
+		spec.Location.Service = service
+	}
+	task, err := vm.Clone(ctx, folder, name, spec)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to clone %v to %v", name, folder.InventoryPath)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed waiting on clone task for %v", name)
+	}
+	clone := object.NewVirtualMachine(target.Conn.Client, info.Result.(types.ManagedObjectReference))
+	clone.InventoryPath = path.Join(folder.InventoryPath, name)
+	return clone, nil
+}
+
+// serviceLocator returns the locator other vCenters use to clone to the session's vCenter
+func (s *Session) serviceLocator() (*types.ServiceLocator, error) {
+	u := *s.Conn.URL()
+	u.User = nil
+	password, _ := s.user.Password()
+	locator := &types.ServiceLocator{
+		InstanceUuid: s.Conn.ServiceContent.About.InstanceUuid,
+		Url:          u.String(),
+		Credential: &types.ServiceLocatorNamePassword{
+			Username: s.user.Username(),
+			Password: password,
+		},
+	}
+	if u.Scheme == "https" {
+		var cert object.HostCertificateInfo
+		if err := cert.FromURL(&u, &tls.Config{InsecureSkipVerify: true}); err != nil {
+			return nil, errors.Wrapf(err, "unable to get the certificate of %v", u.Host)
+		}
+		locator.SslThumbprint = cert.ThumbprintSHA1
+	}
+	return locator, nil
+}
+
+// promotionHistory returns the promotion history in a template's metadata, oldest first
+func promotionHistory(metadata map[string]string) []Promotion {
+	type entry struct {
+		n         int
+		promotion Promotion
+	}
+	var entries []entry
+	for key, value := range metadata {
+		n, err := strconv.Atoi(strings.TrimPrefix(key, metadataPromotion))
+		if !strings.HasPrefix(key, metadataPromotion) || err != nil {
+			continue
+		}
+		var p Promotion
+		if err := json.Unmarshal([]byte(value), &p); err != nil {
+			continue
+		}
+		entries = append(entries, entry{n, p})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].n < entries[j].n })
+	history := []Promotion{}
+	for _, e := range entries {
+		history = append(history, e.promotion)
+	}
+	return history
+}
+
+// moveVM moves a VM or template into a folder
+func moveVM(ctx context.Context, vm *object.VirtualMachine, folder *object.Folder) error {
+	task, err := folder.MoveInto(ctx, []types.ManagedObjectReference{vm.Reference()})
+	if err != nil {
+		return errors.Wrapf(err, "unable to move %v to %v", vm.Reference(), folder.InventoryPath)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "failed waiting on move task for %v", vm.Reference())
+	}
+	vm.InventoryPath = path.Join(folder.InventoryPath, path.Base(vm.InventoryPath))
+	return nil
+}
+
+// reconfigureAnnotation replaces the annotation of a VM or template
+func reconfigureAnnotation(ctx context.Context, vm *object.VirtualMachine, annotation string) error {
+	task, err := vm.Reconfigure(ctx, types.VirtualMachineConfigSpec{Annotation: annotation})
+	if err != nil {
+		return errors.Wrapf(err, "unable to set the annotation of %v", vm.Reference())
+	}
+	if err := task.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "failed waiting on reconfigure task for %v", vm.Reference())
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPromote(t *testing.T) {
+	useTestTargets(t)
+	ctx := context.Background()
+	for _, name := range []string{"staging", "prod", "archive", "remote"} {
+		if _, err := sim.conn.Folder.CreateFolder(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sim.conn.Folder, _ = sim.conn.GetFolderOrDefault("/DC0/vm/staging")
+	imported, err := sim.conn.DeployOVATemplate(newTestOVA(t, testOVF{Name: "promote-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sim.conn.Promote(PromoteOptions{Name: "promote-tiny", From: "staging", To: "prod"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != PromoteMoved || result.Template.Path != "/DC0/vm/prod/promote-tiny" || result.Template.ID != imported.VMObject.Reference().Value {
+		t.Fatalf("expected promote-tiny to be moved to prod, actual: %+v", result)
+	}
+	if len(result.History) != 1 || result.History[0].By != sim.conn.user.Username() || result.History[0].From != "staging" || result.History[0].To != "prod" || result.History[0].At == "" {
+		t.Fatalf("unexpected history %+v", result.History)
+	}
+	if result.Template.Metadata[MetadataSourceDigest] != imported.Source.Digest {
+		t.Fatalf("expected the import metadata to be kept, actual: %v", result.Template.Metadata)
+	}
+	if _, err := sim.conn.Promote(PromoteOptions{Name: "promote-tiny", From: "staging", To: "prod"}); err == nil {
+		t.Fatal("expected an error for a template that isn't in staging")
+	}
+
+	if err := sim.conn.attachTag(ctx, imported.VMObject, "stage", "qa"); err != nil {
+		t.Fatal(err)
+	}
+	result, err = sim.conn.Promote(PromoteOptions{Name: "promote-tiny", From: "tag:stage/qa", To: "tag:stage/release"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Template.Tags) != 1 || result.Template.Tags[0] != "stage/release" || result.Template.Path != "/DC0/vm/prod/promote-tiny" {
+		t.Fatalf("expected the qa tag to be replaced by release, actual: %+v", result.Template)
+	}
+	if len(result.History) != 2 || result.History[1].From != "tag:stage/qa" {
+		t.Fatalf("unexpected history %+v", result.History)
+	}
+
+	createTestDatastore(t, "DC0_H0", "PromoteDS")
+	sim.conn.Datastore, _ = sim.conn.GetDatastoreOrDefault("/DC0/datastore/PromoteDS")
+	if _, err := sim.conn.Promote(PromoteOptions{Name: "promote-tiny", From: "prod", To: "tag:stage/archived", Clone: true}); err == nil {
+		t.Fatal("expected an error for a clone to a tag")
+	}
+	result, err = sim.conn.Promote(PromoteOptions{Name: "promote-tiny", From: "prod", To: "archive", Clone: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != PromoteCloned || result.Template.Path != "/DC0/vm/archive/promote-tiny" || result.Template.ID == imported.VMObject.Reference().Value || result.Template.Kind != KindTemplate {
+		t.Fatalf("expected promote-tiny to be cloned to archive, actual: %+v", result)
+	}
+	if len(result.History) != 3 {
+		t.Fatalf("expected 3 promotions, actual: %+v", result.History)
+	}
+	original, err := sim.conn.DescribeTemplate("/DC0/vm/prod/promote-tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(promotionHistory(original.Metadata)) != 2 {
+		t.Fatalf("expected the cloned template to keep its history, actual: %v", original.Metadata)
+	}
+}
+
+func TestPromoteToVCenter(t *testing.T) {
+	useTestTargets(t)
+	if _, err := sim.conn.Folder.CreateFolder(context.Background(), "xvc"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.conn.DeployOVATemplate(newTestOVA(t, testOVF{Name: "xvc-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})); err != nil {
+		t.Fatal(err)
+	}
+
+	// the simulator is both vCenters
+	password, _ := sim.server.URL.User.Password()
+	target, err := NewClient(sim.conn.Ctx, "https://"+sim.server.URL.Host, sim.server.URL.User.Username(), password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	target.Datacenter = sim.conn.Datacenter
+	target.ResourcePool = sim.conn.ResourcePool
+	createTestDatastore(t, "DC0_H0", "RemoteDS")
+	if target.Datastore, err = target.GetDatastoreOrDefault("/DC0/datastore/RemoteDS"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := sim.conn.Promote(PromoteOptions{Name: "xvc-tiny", From: "/DC0/vm", To: "/DC0/vm/xvc", Target: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Action != PromoteCloned || result.Template.Path != "/DC0/vm/xvc/xvc-tiny" {
+		t.Fatalf("expected xvc-tiny to be cloned to the other vCenter, actual: %+v", result)
+	}
+	if len(result.History) != 1 || result.History[0].VCenter != sim.server.URL.Host {
+		t.Fatalf("expected the source vCenter in the history, actual: %+v", result.History)
+	}
+	locator, err := target.serviceLocator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locator.SslThumbprint == "" || locator.InstanceUuid != target.Conn.ServiceContent.About.InstanceUuid {
+		t.Fatalf("unexpected service locator %+v", locator)
+	}
+}
+
+func TestPromotionHistory(t *testing.T) {
+	metadata := map[string]string{
+		"promotion.10":         `{"at":"c","by":"u","from":"b","to":"c"}`,
+		"promotion.2":          `{"at":"b","by":"u","from":"a","to":"b"}`,
+		"promotion.1":          `{"at":"a","by":"u","from":"staging","to":"a"}`,
+		"promotion.x":          `{"at":"x"}`,
+		MetadataSourceDigest:   "abc",
+		metadataPromotion + "": "not json",
+	}
+	history := promotionHistory(metadata)
+	if len(history) != 3 || history[0].From != "staging" || history[1].At != "b" || history[2].At != "c" {
+		t.Fatalf("unexpected history %+v", history)
+	}
+}
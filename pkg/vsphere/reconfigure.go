@@ -0,0 +1,155 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// Reconfig is a hardware change applied to the imported VM before it is marked as a template
+type Reconfig struct {
+	NumCPUs        int32 `json:"numCPUs,omitempty" yaml:"numCPUs"`
+	CoresPerSocket int32 `json:"coresPerSocket,omitempty" yaml:"coresPerSocket"`
+	MemoryMB       int64 `json:"memoryMB,omitempty" yaml:"memoryMB"`
+	// DiskSizeGB grows disks to a new size, keyed by device label (Hard disk 1)
+	DiskSizeGB map[string]int64 `json:"diskSizeGB,omitempty" yaml:"diskSizeGB"`
+	// EnableDiskUUID sets disk.EnableUUID=TRUE, needed by the vSphere CSI driver and CAPV
+	EnableDiskUUID bool              `json:"enableDiskUUID,omitempty" yaml:"enableDiskUUID"`
+	ExtraConfig    map[string]string `json:"extraConfig,omitempty" yaml:"extraConfig"`
+	// Firmware is bios or efi
+	Firmware   string `json:"firmware,omitempty" yaml:"firmware"`
+	SecureBoot *bool  `json:"secureBoot,omitempty" yaml:"secureBoot"`
+}
+
+// ReadReconfig reads a Reconfig from a YAML file
+func ReadReconfig(file string) (Reconfig, error) {
+	var r Reconfig
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return r, errors.Wrapf(err, "unable to read reconfig file %v", file)
+	}
+	if err := yaml.UnmarshalStrict(data, &r); err != nil {
+		return r, errors.Wrapf(err, "unable to parse reconfig file %v", file)
+	}
+	return r, nil
+}
+
+// IsZero is true when the Reconfig changes nothing
+func (r Reconfig) IsZero() bool {
+	return r.NumCPUs == 0 && r.CoresPerSocket == 0 && r.MemoryMB == 0 && len(r.DiskSizeGB) == 0 &&
+		!r.EnableDiskUUID && len(r.ExtraConfig) == 0 && r.Firmware == "" && r.SecureBoot == nil
+}
+
+// validate checks the CPU and firmware changes, which don't depend on the VM, before anything is uploaded
+func (r Reconfig) validate() error {
+	if r.NumCPUs > 0 && r.CoresPerSocket > 0 && r.NumCPUs%r.CoresPerSocket != 0 {
+		return errors.New(fmt.Sprintf("%v CPUs can't be split into sockets of %v cores", r.NumCPUs, r.CoresPerSocket))
+	}
+	switch r.Firmware {
+	case "", string(types.GuestOsDescriptorFirmwareTypeBios), string(types.GuestOsDescriptorFirmwareTypeEfi):
+	default:
+		return errors.New(fmt.Sprintf("unknown firmware %v, must be bios or efi", r.Firmware))
+	}
+	if r.SecureBoot != nil && *r.SecureBoot && r.Firmware == string(types.GuestOsDescriptorFirmwareTypeBios) {
+		return errors.New("secure boot needs efi firmware")
+	}
+	return nil
+}
+
+// configSpec returns the spec that applies the Reconfig to a VM with the given devices
+func (r Reconfig) configSpec(devices object.VirtualDeviceList) (types.VirtualMachineConfigSpec, error) {
+	spec := types.VirtualMachineConfigSpec{
+		NumCPUs:           r.NumCPUs,
+		NumCoresPerSocket: r.CoresPerSocket,
+		MemoryMB:          r.MemoryMB,
+		Firmware:          r.Firmware,
+	}
+	if err := r.validate(); err != nil {
+		return spec, err
+	}
+	if r.SecureBoot != nil {
+		spec.BootOptions = &types.VirtualMachineBootOptions{EfiSecureBootEnabled: r.SecureBoot}
+	}
+
+	extraConfig := make(map[string]string, len(r.ExtraConfig)+1)
+	for k, v := range r.ExtraConfig {
+		extraConfig[k] = v
+	}
+	if r.EnableDiskUUID {
+		extraConfig["disk.EnableUUID"] = "TRUE"
+	}
+	for _, k := range sortedKeys(extraConfig) {
+		spec.ExtraConfig = append(spec.ExtraConfig, &types.OptionValue{Key: k, Value: extraConfig[k]})
+	}
+
+	for _, label := range sortedDiskLabels(r.DiskSizeGB) {
+		disk := findDisk(devices, label)
+		if disk == nil {
+			return spec, errors.New(fmt.Sprintf("disk %q not found", label))
+		}
+		size := r.DiskSizeGB[label] << 30
+		if size < disk.CapacityInBytes || size < disk.CapacityInKB<<10 {
+			return spec, errors.New(fmt.Sprintf("disk %q can only grow, it is larger than %vGB", label, r.DiskSizeGB[label]))
+		}
+		disk.CapacityInBytes = size
+		disk.CapacityInKB = size >> 10
+		spec.DeviceChange = append(spec.DeviceChange, &types.VirtualDeviceConfigSpec{
+			Operation: types.VirtualDeviceConfigSpecOperationEdit,
+			Device:    disk,
+		})
+	}
+	return spec, nil
+}
+
+// reconfigureVM applies a Reconfig to a VM
+func reconfigureVM(ctx context.Context, vm *object.VirtualMachine, r Reconfig) error {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get devices of vm %s (%s)", vm.InventoryPath, vm.Reference())
+	}
+	spec, err := r.configSpec(devices)
+	if err != nil {
+		return err
+	}
+	return reconfigure(ctx, vm, spec)
+}
+
+// reconfigure runs a reconfigure task on a VM and waits for it to finish
+func reconfigure(ctx context.Context, vm *object.VirtualMachine, spec types.VirtualMachineConfigSpec) error {
+	task, err := vm.Reconfigure(ctx, spec)
+	if err != nil {
+		return errors.Wrapf(err, "could not reconfigure vm %s (%s)", vm.InventoryPath, vm.Reference())
+	}
+
+	if err := task.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "failed waiting on vm reconfigure task for %s (%s)", vm.InventoryPath, vm.Reference())
+	}
+	return nil
+}
+
+// findDisk returns the disk with a device label (Hard disk 1) or device name (disk-1000-0)
+func findDisk(devices object.VirtualDeviceList, label string) *types.VirtualDisk {
+	for _, device := range devices.SelectByType((*types.VirtualDisk)(nil)) {
+		info := device.GetVirtualDevice().DeviceInfo
+		if (info != nil && info.GetDescription().Label == label) || devices.Name(device) == label {
+			return device.(*types.VirtualDisk)
+		}
+	}
+	return nil
+}
+
+func sortedDiskLabels(m map[string]int64) []string {
+	labels := make([]string, 0, len(m))
+	for k := range m {
+		labels = append(labels, k)
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,160 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestDeployOVATemplateReconfig(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Reconfig = Reconfig{
+		NumCPUs:        4,
+		CoresPerSocket: 2,
+		MemoryMB:       2048,
+		DiskSizeGB:     map[string]int64{"Hard disk 1": 2},
+		EnableDiskUUID: true,
+		ExtraConfig:    map[string]string{"guestinfo.role": "node"},
+	}
+	ovaPath := newTestOVA(t, testOVF{Name: "reconfig-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, err := getProperties(sim.conn.Ctx, info.VMObject)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hw := props.Config.Hardware
+	if hw.NumCPU != 4 || hw.NumCoresPerSocket != 2 || hw.MemoryMB != 2048 {
+		t.Fatalf("expected 4 CPUs, 2 cores per socket and 2048MB, actual: %v, %v, %v", hw.NumCPU, hw.NumCoresPerSocket, hw.MemoryMB)
+	}
+	extraConfig := make(map[string]string)
+	for _, opt := range props.Config.ExtraConfig {
+		extraConfig[opt.GetOptionValue().Key], _ = opt.GetOptionValue().Value.(string)
+	}
+	if extraConfig["disk.EnableUUID"] != "TRUE" || extraConfig["guestinfo.role"] != "node" {
+		t.Fatalf("expected extraConfig to be set, actual: %v", extraConfig)
+	}
+	disk := findDisk(object.VirtualDeviceList(hw.Device), "Hard disk 1")
+	if disk == nil || disk.CapacityInKB != 2<<20 {
+		t.Fatalf("expected the disk to grow to 2GB, actual: %+v", disk)
+	}
+}
+
+func TestDeployOVATemplateReconfigFailure(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Reconfig = Reconfig{DiskSizeGB: map[string]int64{"Hard disk 2": 8}}
+	ovaPath := newTestOVA(t, testOVF{Name: "reconfig-failure-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if info.VMObject != nil {
+		t.Fatalf("expected no VM in the result, actual: %v", info.VMObject)
+	}
+	if _, err := sim.conn.GetVM("reconfig-failure-tiny"); err == nil {
+		t.Fatal("expected the half configured import to be deleted")
+	}
+
+	// the next run imports it again instead of finding it
+	sim.conn.Options.Reconfig = Reconfig{}
+	info, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AlreadyExists {
+		t.Fatal("expected a new import")
+	}
+}
+
+func TestDeployOVATemplateReconfigInvalid(t *testing.T) {
+	secureBoot := true
+	tests := map[string]Reconfig{
+		"unknown firmware":  {Firmware: "uboot"},
+		"bios secure boot":  {Firmware: "bios", SecureBoot: &secureBoot},
+		"uneven core count": {NumCPUs: 3, CoresPerSocket: 2},
+	}
+	for name, reconfig := range tests {
+		t.Run(name, func(t *testing.T) {
+			useTestTargets(t)
+			recorder := &importSpecRecorder{RoundTripper: sim.conn.Conn.Client.RoundTripper}
+			sim.conn.Conn.Client.RoundTripper = recorder
+			t.Cleanup(func() { sim.conn.Conn.Client.RoundTripper = recorder.RoundTripper })
+			sim.conn.Options.Reconfig = reconfig
+			ovaPath := newTestOVA(t, testOVF{Name: "reconfig-invalid-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+			if _, err := sim.conn.DeployOVATemplate(ovaPath); err == nil {
+				t.Fatal("received an unexpected nil error")
+			}
+			if len(recorder.specs) != 0 {
+				t.Fatal("expected the reconfig to be rejected before the upload")
+			}
+		})
+	}
+}
+
+func TestReconfigConfigSpecErrors(t *testing.T) {
+	disk := &types.VirtualDisk{CapacityInBytes: 4 << 30}
+	disk.DeviceInfo = &types.Description{Label: "Hard disk 1"}
+	devices := object.VirtualDeviceList{disk}
+	secureBoot := true
+
+	tests := map[string]struct {
+		reconfig Reconfig
+		errMsg   string
+	}{
+		"shrink disk":       {Reconfig{DiskSizeGB: map[string]int64{"Hard disk 1": 2}}, `disk "Hard disk 1" can only grow, it is larger than 2GB`},
+		"missing disk":      {Reconfig{DiskSizeGB: map[string]int64{"Hard disk 2": 8}}, `disk "Hard disk 2" not found`},
+		"unknown firmware":  {Reconfig{Firmware: "uboot"}, "unknown firmware uboot, must be bios or efi"},
+		"bios secure boot":  {Reconfig{Firmware: "bios", SecureBoot: &secureBoot}, "secure boot needs efi firmware"},
+		"uneven core count": {Reconfig{NumCPUs: 3, CoresPerSocket: 2}, "3 CPUs can't be split into sockets of 2 cores"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tc.reconfig.configSpec(devices)
+			if err == nil {
+				t.Fatal("received an unexpected nil error")
+			}
+			if err.Error() != tc.errMsg {
+				t.Fatalf("expected: %v, actual: %v", tc.errMsg, err.Error())
+			}
+		})
+	}
+}
+
+func TestReadReconfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "reconfig.yaml")
+	data := "numCPUs: 2\nenableDiskUUID: true\nsecureBoot: false\nextraConfig:\n  disk.EnableUUID: \"TRUE\"\ndiskSizeGB:\n  Hard disk 1: 40\n"
+	if err := ioutil.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ReadReconfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NumCPUs != 2 || !r.EnableDiskUUID || r.SecureBoot == nil || *r.SecureBoot || r.DiskSizeGB["Hard disk 1"] != 40 || r.ExtraConfig["disk.EnableUUID"] != "TRUE" {
+		t.Fatalf("unexpected reconfig: %+v", r)
+	}
+
+	if err := ioutil.WriteFile(file, []byte("numCPU: 2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadReconfig(file); err == nil {
+		t.Fatal("expected unknown keys to be refused")
+	}
+}
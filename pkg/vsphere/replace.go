@@ -0,0 +1,92 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/ovf"
+)
+
+// What happens to a template after it was replaced
+const (
+	ReplaceOldDelete = "delete"
+	ReplaceOldKeep   = "keep"
+)
+
+// ReplaceResult is the outcome of replacing an existing template with a new import
+type ReplaceResult struct {
+	// Previous is the managed object reference of the replaced template
+	Previous string `json:"previous"`
+	// PreviousName is the name the replaced template was renamed to, it is kept when Action is kept
+	PreviousName string `json:"previousName"`
+	// Action is what was done with the replaced template, deleted or kept
+	Action       string `json:"action"`
+	CleanupError string `json:"cleanupError,omitempty"`
+}
+
+func (o DeployOptions) replaceOld() string {
+	if o.ReplaceOld == "" {
+		return ReplaceOldDelete
+	}
+	return o.ReplaceOld
+}
+
+// replace imports the OVA under a temporary name next to the existing template, then renames the existing template to
+// <name>-old-<timestamp> and the new import to name. The existing template is left as it was when the import, its smoke
+// test or the first rename fails, and renamed back when the second rename fails. Afterwards it's deleted or kept as set
+// by the ReplaceOld option.
+func (s *Session) replace(ctx context.Context, existing *object.VirtualMachine, templatePath string, name string, env *ovf.Envelope, result *DeployInfo) error {
+	switch s.Options.replaceOld() {
+	case ReplaceOldDelete, ReplaceOldKeep:
+	default:
+		return errors.New(fmt.Sprintf("unknown replace policy %v, must be delete or keep", s.Options.ReplaceOld))
+	}
+
+	now := time.Now()
+	tempName := suffixedName(name, fmt.Sprintf("-new-%d", now.UnixNano()))
+	if err := s.importOVA(ctx, templatePath, tempName, env, result); err != nil {
+		// a template that failed its smoke test was already deleted or quarantined
+		if result.VMObject == nil || result.SmokeTest != nil {
+			return errors.WithMessagef(err, "unable to replace %v, it was left as is", name)
+		}
+		return discardImport(ctx, result.VMObject, name, err)
+	}
+
+	replaced := &ReplaceResult{
+		Previous:     existing.Reference().Value,
+		PreviousName: suffixedName(name, fmt.Sprintf("-old-%d", now.Unix())),
+	}
+	if err := renameVM(ctx, existing, replaced.PreviousName); err != nil {
+		return discardImport(ctx, result.VMObject, name, err)
+	}
+	if err := renameVM(ctx, result.VMObject, name); err != nil {
+		if rollbackErr := renameVM(ctx, existing, name); rollbackErr != nil {
+			return errors.WithMessagef(err, "unable to replace %v and to rename %v back (%v)", name, replaced.PreviousName, rollbackErr)
+		}
+		return discardImport(ctx, result.VMObject, name, err)
+	}
+	result.Replaced = replaced
+
+	if s.Options.replaceOld() == ReplaceOldKeep {
+		replaced.Action = "kept"
+		return nil
+	}
+	replaced.Action = "deleted"
+	if err := destroyVM(ctx, existing); err != nil {
+		replaced.Action = "kept"
+		replaced.CleanupError = err.Error()
+	}
+	return nil
+}
+
+// discardImport deletes the new import of a replace that failed, the replaced template was left as is
+func discardImport(ctx context.Context, vm *object.VirtualMachine, name string, err error) error {
+	if cleanupErr := destroyVM(ctx, vm); cleanupErr != nil {
+		return errors.WithMessagef(err, "unable to replace %v, it was left as is but the new import %v could not be deleted (%v)", name, vm.Reference().Value, cleanupErr)
+	}
+	return errors.WithMessagef(err, "unable to replace %v, it was left as is", name)
+}
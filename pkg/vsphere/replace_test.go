@@ -0,0 +1,123 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestDeployOVATemplateReplace(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "replace-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+	original, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim.conn.Options.Replace = true
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AlreadyExists || info.Replaced == nil {
+		t.Fatalf("expected the template to be replaced, actual: %+v", info)
+	}
+	if info.Replaced.Previous != original.VMObject.Reference().Value || info.Replaced.Action != "deleted" {
+		t.Fatalf("unexpected replace result %+v", info.Replaced)
+	}
+	vm, err := sim.conn.GetVM("replace-tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Reference() != info.VMObject.Reference() {
+		t.Fatalf("expected: %v, actual: %v", info.VMObject.Reference(), vm.Reference())
+	}
+	if _, err := sim.conn.GetVM(info.Replaced.PreviousName); err == nil {
+		t.Fatalf("expected %v to be deleted", info.Replaced.PreviousName)
+	}
+	if info.Kind != KindTemplate {
+		t.Fatalf("expected: %v, actual: %v", KindTemplate, info.Kind)
+	}
+
+	sim.conn.Options.ReplaceOld = ReplaceOldKeep
+	kept, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept.Replaced == nil || kept.Replaced.Action != "kept" {
+		t.Fatalf("expected the old template to be kept, actual: %+v", kept.Replaced)
+	}
+	old, err := sim.conn.GetVM(kept.Replaced.PreviousName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if old.Reference() != info.VMObject.Reference() {
+		t.Fatalf("expected: %v, actual: %v", info.VMObject.Reference(), old.Reference())
+	}
+}
+
+func TestDeployOVATemplateReplaceFailure(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "replace-failure-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+	original, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := &importSpecRecorder{RoundTripper: sim.conn.Conn.Client.RoundTripper}
+	sim.conn.Conn.Client.RoundTripper = recorder
+	t.Cleanup(func() { sim.conn.Conn.Client.RoundTripper = recorder.RoundTripper })
+	sim.conn.Options.Replace = true
+	sim.conn.Options.Reconfig = Reconfig{DiskSizeGB: map[string]int64{"Hard disk 9": 10}}
+	if _, err := sim.conn.DeployOVATemplate(ovaPath); err == nil || !strings.Contains(err.Error(), "left as is") {
+		t.Fatalf("expected the replace to fail, actual: %v", err)
+	}
+	if len(recorder.specs) != 1 {
+		t.Fatalf("expected 1 import, actual: %v", len(recorder.specs))
+	}
+	tempName := recorder.specs[0].(*types.VirtualMachineImportSpec).ConfigSpec.Name
+	if !strings.HasPrefix(tempName, "replace-failure-tiny-new-") {
+		t.Fatalf("expected the new import to have a temporary name, actual: %v", tempName)
+	}
+	vm, err := sim.conn.GetVM("replace-failure-tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Reference() != original.VMObject.Reference() {
+		t.Fatalf("expected the original template %v, actual: %v", original.VMObject.Reference(), vm.Reference())
+	}
+	sim.conn.Folder = nil
+	leftover, err := sim.conn.findExisting(sim.conn.Ctx, tempName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leftover != nil {
+		t.Fatalf("expected the new import %v to be deleted, found %v", tempName, leftover.InventoryPath)
+	}
+}
+
+func TestDeployOVATemplateReplaceUnknownPolicy(t *testing.T) {
+	useTestTargets(t)
+	ovaPath := newTestOVA(t, testOVF{Name: "replace-policy-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+	if _, err := sim.conn.DeployOVATemplate(ovaPath); err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Options.Replace = true
+	sim.conn.Options.ReplaceOld = "archive"
+	if _, err := sim.conn.DeployOVATemplate(ovaPath); err == nil {
+		t.Fatal("expected an error for an unknown replace policy")
+	}
+}
+
+func TestSuffixedName(t *testing.T) {
+	name := suffixedName(strings.Repeat("a", maxNameLength), "-old-1600000000")
+	if len(name) != maxNameLength || !strings.HasSuffix(name, "-old-1600000000") {
+		t.Fatalf("unexpected name %v", name)
+	}
+	if name := suffixedName("tiny", "-old-1"); name != "tiny-old-1" {
+		t.Fatalf("expected: tiny-old-1, actual: %v", name)
+	}
+}
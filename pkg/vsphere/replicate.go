@@ -0,0 +1,213 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ReplicateAllAccessible replicates the template to every datastore usable by the session's hosts
+const ReplicateAllAccessible = "all-accessible"
+
+// Replica states
+const (
+	ReplicaCreated  = "created"
+	ReplicaExists   = "exists"
+	ReplicaReplaced = "replaced"
+	ReplicaFailed   = "failed"
+)
+
+// defaultReplicaConcurrency is the number of replicas cloned at the same time when ReplicaConcurrency isn't set
+const defaultReplicaConcurrency = 4
+
+// Replica is a clone of the imported template on another datastore
+type Replica struct {
+	Name      string `json:"name"`
+	ID        string `json:"id,omitempty"`
+	Datastore string `json:"datastore"`
+	Host      string `json:"host,omitempty"`
+	// Status is created, exists, replaced (the replica was cloned from an older import) or failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (o DeployOptions) replicaConcurrency() int {
+	if o.ReplicaConcurrency <= 0 {
+		return defaultReplicaConcurrency
+	}
+	return o.ReplicaConcurrency
+}
+
+// replicaDatastores returns the datastores of the ReplicateTo option: all-accessible, regex:<pattern> or a comma
+// separated list of names. Only datastores usable by the session's hosts are replicated to.
+func (s *Session) replicaDatastores(ctx context.Context) ([]datastoreCandidate, error) {
+	policy := s.Options.ReplicateTo
+	if s.IsESXi() {
+		return nil, errors.New("replicas need a vCenter, not a standalone ESXi host")
+	}
+	candidates, err := s.datastoreCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case policy == ReplicateAllAccessible:
+		return candidates, nil
+	case strings.HasPrefix(policy, DatastoreSelectRegex):
+		re, err := regexp.Compile(strings.TrimPrefix(policy, DatastoreSelectRegex))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid replica datastores %v", policy)
+		}
+		var matched []datastoreCandidate
+		for _, ds := range candidates {
+			if re.MatchString(ds.Name) {
+				matched = append(matched, ds)
+			}
+		}
+		return matched, nil
+	}
+	byName := make(map[string]datastoreCandidate, len(candidates))
+	for _, ds := range candidates {
+		byName[ds.Name] = ds
+	}
+	var listed []datastoreCandidate
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(policy, ",") {
+		name = strings.TrimSpace(name)
+		ds, ok := byName[name]
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("datastore %v doesn't exist or isn't usable by the hosts of the import", name))
+		}
+		if !seen[name] {
+			seen[name] = true
+			listed = append(listed, ds)
+		}
+	}
+	return listed, nil
+}
+
+// replicate clones a template to datastores, except the ones it's already on, with at most ReplicaConcurrency
+// clones at the same time. A replica that already exists is kept when it was cloned from the same import.
+// The replicas are sorted by datastore.
+func (s *Session) replicate(ctx context.Context, template *object.VirtualMachine, name string, datastores []datastoreCandidate) ([]Replica, error) {
+	var props mo.VirtualMachine
+	if err := template.Properties(ctx, template.Reference(), []string{"config.template", "config.annotation", "datastore"}, &props); err != nil {
+		return nil, errors.Wrapf(err, "unable to get the properties of %v", name)
+	}
+	hosts, err := s.placementHosts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []datastoreCandidate
+	for _, ds := range datastores {
+		if !containsRef(props.Datastore, ds.Reference()) {
+			targets = append(targets, ds)
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	replicas := make([]Replica, len(targets))
+	sem := make(chan struct{}, s.Options.replicaConcurrency())
+	var wg sync.WaitGroup
+	for i, ds := range targets {
+		wg.Add(1)
+		go func(i int, ds datastoreCandidate) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			replicas[i] = s.replicateTo(ctx, template, name, props, ds, usableHost(ds.Datastore, hosts))
+		}(i, ds)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, replica := range replicas {
+		if replica.Status == ReplicaFailed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return replicas, errors.New(fmt.Sprintf("unable to replicate %v to %d of %d datastores", name, failed, len(replicas)))
+	}
+	return replicas, nil
+}
+
+// replicateTo clones a template to a datastore as <name>-<datastore>, placed on host when it's set
+func (s *Session) replicateTo(ctx context.Context, template *object.VirtualMachine, name string, props mo.VirtualMachine, ds datastoreCandidate, host *mo.HostSystem) Replica {
+	replica := Replica{Name: suffixedName(name, "-"+ds.Name), Datastore: ds.Name, Status: ReplicaCreated}
+	if host != nil {
+		replica.Host = host.Name
+	}
+	fail := func(err error) Replica {
+		replica.Status = ReplicaFailed
+		replica.Error = err.Error()
+		return replica
+	}
+	annotation := ""
+	if props.Config != nil {
+		annotation = props.Config.Annotation
+	}
+
+	existing, err := s.findExisting(ctx, replica.Name)
+	if err != nil {
+		return fail(err)
+	}
+	if existing != nil {
+		var current mo.VirtualMachine
+		if err := existing.Properties(ctx, existing.Reference(), []string{"config.annotation"}, &current); err != nil {
+			return fail(errors.Wrapf(err, "unable to get the properties of %v", replica.Name))
+		}
+		digest := getMetadata(annotation)[MetadataSourceDigest]
+		if current.Config == nil || digest == "" || getMetadata(current.Config.Annotation)[MetadataSourceDigest] == digest {
+			replica.ID = existing.Reference().Value
+			replica.Status = ReplicaExists
+			return replica
+		}
+		// the replica was cloned from an older import of the template
+		if err := destroyVM(ctx, existing); err != nil {
+			return fail(err)
+		}
+		replica.Status = ReplicaReplaced
+	}
+
+	pool := s.ResourcePool.Reference()
+	spec := types.VirtualMachineCloneSpec{
+		Location: types.VirtualMachineRelocateSpec{Pool: &pool, Datastore: types.NewReference(ds.Reference())},
+		Template: props.Config != nil && props.Config.Template,
+	}
+	if host != nil {
+		spec.Location.Host = types.NewReference(host.Reference())
+	}
+	task, err := template.Clone(ctx, s.Folder, replica.Name, spec)
+	if err != nil {
+		return fail(errors.Wrapf(err, "unable to clone %v to %v", name, ds.Name))
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return fail(errors.Wrapf(err, "failed waiting on clone task for %v", replica.Name))
+	}
+	clone := object.NewVirtualMachine(s.Conn.Client, info.Result.(types.ManagedObjectReference))
+	replica.ID = clone.Reference().Value
+	if err := reconfigureAnnotation(ctx, clone, annotation); err != nil {
+		return fail(err)
+	}
+	return replica
+}
+
+// usableHost returns a host that mounts the datastore and can access it, nil when there are no hosts to choose from
+func usableHost(ds mo.Datastore, hosts []mo.HostSystem) *mo.HostSystem {
+	for i := range hosts {
+		if mountedOnAny(ds, hosts[i:i+1]) {
+			return &hosts[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDeployOVATemplateReplicas(t *testing.T) {
+	useTestTargets(t)
+	createTestDatastore(t, "DC0_H0", "ReplicaDS1")
+	createTestDatastore(t, "DC0_H0", "ReplicaDS2")
+	sim.conn.Options.ReplicateTo = "regex:^ReplicaDS"
+	sim.conn.Options.ReplicaConcurrency = 1
+	ovaPath := newTestOVA(t, testOVF{Name: "replica-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replicas := func(info DeployInfo) string {
+		var s []string
+		for _, r := range info.Replicas {
+			s = append(s, fmt.Sprintf("%v@%v:%v", r.Name, r.Datastore, r.Status))
+		}
+		return fmt.Sprint(s)
+	}
+	expected := "[replica-tiny-ReplicaDS1@ReplicaDS1:created replica-tiny-ReplicaDS2@ReplicaDS2:created]"
+	if replicas(info) != expected {
+		t.Fatalf("expected: %v, actual: %v", expected, replicas(info))
+	}
+	replica, err := sim.conn.DescribeTemplate("replica-tiny-ReplicaDS1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replica.Metadata[MetadataSourceDigest] != info.Source.Digest || info.Replicas[0].Host != "DC0_H0" {
+		t.Fatalf("expected the replica to have the metadata of the import, actual: %+v", replica)
+	}
+
+	// a re-run keeps the replicas
+	info, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "[replica-tiny-ReplicaDS1@ReplicaDS1:exists replica-tiny-ReplicaDS2@ReplicaDS2:exists]"
+	if !info.AlreadyExists || replicas(info) != expected {
+		t.Fatalf("expected: %v, actual: %v", expected, replicas(info))
+	}
+
+	// a replica of another import is replaced
+	vm, err := sim.conn.GetVM("replica-tiny-ReplicaDS2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reconfigureAnnotation(sim.conn.Ctx, vm, setMetadata("", map[string]string{MetadataSourceDigest: "older"})); err != nil {
+		t.Fatal(err)
+	}
+	sim.conn.Options.ReplicateTo = "ReplicaDS1, ReplicaDS2"
+	info, err = sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "[replica-tiny-ReplicaDS1@ReplicaDS1:exists replica-tiny-ReplicaDS2@ReplicaDS2:replaced]"
+	if replicas(info) != expected {
+		t.Fatalf("expected: %v, actual: %v", expected, replicas(info))
+	}
+}
+
+func TestReplicaDatastores(t *testing.T) {
+	useTestTargets(t)
+	tests := map[string]struct {
+		policy   string
+		expected []string
+		err      bool
+	}{
+		"all accessible":  {ReplicateAllAccessible, []string{"LocalDS_0"}, false},
+		"regex":           {"regex:^Local", []string{"LocalDS_0"}, false},
+		"list":            {"LocalDS_0, LocalDS_0", []string{"LocalDS_0"}, false},
+		"unknown":         {"LocalDS_0,NoSuchDS", nil, true},
+		"invalid regex":   {"regex:(", nil, true},
+		"no such pattern": {"regex:^NoSuch", nil, false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			sim.conn.Options.ReplicateTo = tc.policy
+			datastores, err := sim.conn.replicaDatastores(sim.conn.Ctx)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %v, actual: %v", tc.err, err)
+			}
+			names := make(map[string]bool)
+			for _, ds := range datastores {
+				names[ds.Name] = true
+			}
+			// other tests add datastores, only the expected ones are checked for all accessible
+			if len(datastores) < len(tc.expected) || (tc.policy != ReplicateAllAccessible && len(datastores) != len(tc.expected)) {
+				t.Fatalf("expected: %v, actual: %v", tc.expected, names)
+			}
+			for _, expected := range tc.expected {
+				if !names[expected] {
+					t.Fatalf("expected %v in %v", expected, names)
+				}
+			}
+		})
+	}
+}
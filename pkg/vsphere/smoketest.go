@@ -0,0 +1,248 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// What happens to a template that fails its smoke test
+const (
+	SmokeTestFailureDelete     = "delete"
+	SmokeTestFailureQuarantine = "quarantine"
+)
+
+// quarantineSuffix is appended to the name of a quarantined template
+const quarantineSuffix = "-quarantined"
+
+// defaultSmokeTestTimeout limits a smoke test without SmokeTestTimeout when the context has no deadline either
+const defaultSmokeTestTimeout = 10 * time.Minute
+
+// SmokeTestResult is the outcome of booting a clone of the imported template
+type SmokeTestResult struct {
+	Passed          bool    `json:"passed"`
+	VM              string  `json:"vm"`
+	ToolsStatus     string  `json:"toolsStatus,omitempty"`
+	IPAddress       string  `json:"ipAddress,omitempty"`
+	Port            int     `json:"port,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	Error           string  `json:"error,omitempty"`
+	// Action is what was done with the template after a failure, deleted or quarantined
+	Action string `json:"action,omitempty"`
+	// QuarantinedAs is the name of a quarantined template
+	QuarantinedAs string `json:"quarantinedAs,omitempty"`
+	CleanupError  string `json:"cleanupError,omitempty"`
+}
+
+// smokeTestCleanupTime is kept from the deadline of the context for the smoke test to destroy its clone and to delete
+// or quarantine a template that failed
+const smokeTestCleanupTime = 30 * time.Second
+
+// smokeTestContext limits ctx to SmokeTestTimeout. Without one the smoke test gets what is left of ctx, or
+// defaultSmokeTestTimeout when ctx has no deadline. It ends smokeTestCleanupTime before the deadline of ctx either way.
+func (o DeployOptions) smokeTestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := o.SmokeTestTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := time.Until(deadline) - smokeTestCleanupTime; timeout <= 0 || left < timeout {
+			timeout = left
+		}
+	} else if timeout <= 0 {
+		timeout = defaultSmokeTestTimeout
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+func (o DeployOptions) smokeTestFailure() string {
+	if o.SmokeTestFailure == "" {
+		return SmokeTestFailureDelete
+	}
+	return o.SmokeTestFailure
+}
+
+// validateSmokeTest checks the smoke test options before anything is uploaded
+func (s *Session) validateSmokeTest() error {
+	if !s.Options.SmokeTest {
+		return nil
+	}
+	if s.IsESXi() {
+		return errors.New("the smoke test clones the import and needs a vCenter, not a standalone ESXi host")
+	}
+	if s.Options.nicPolicy() == NICPolicyRemove {
+		return errors.New("the smoke test waits for an IP and can't pass with --nic-policy remove")
+	}
+	switch s.Options.smokeTestFailure() {
+	case SmokeTestFailureDelete, SmokeTestFailureQuarantine:
+	default:
+		return errors.New(fmt.Sprintf("unknown smoke test failure action %v, must be delete or quarantine", s.Options.SmokeTestFailure))
+	}
+	return nil
+}
+
+// smokeTest clones the template to a throwaway VM, powers it on and waits for VMware Tools to run and an IP to appear,
+// then optionally probes SmokeTestPort on that IP. The clone is always destroyed. When the test fails the template
+// is deleted or quarantined as set by SmokeTestFailure.
+func (s *Session) smokeTest(ctx context.Context, template *object.VirtualMachine, templateName string) SmokeTestResult {
+	result := SmokeTestResult{
+		VM:   fmt.Sprintf("%v-smoketest-%d", templateName, time.Now().Unix()),
+		Port: s.Options.SmokeTestPort,
+	}
+	start := time.Now()
+	err := s.bootClone(ctx, template, &result)
+	result.DurationSeconds = time.Since(start).Seconds()
+	if err == nil {
+		result.Passed = true
+		return result
+	}
+	result.Error = err.Error()
+
+	switch s.Options.smokeTestFailure() {
+	case SmokeTestFailureQuarantine:
+		result.Action = "quarantined"
+		if result.QuarantinedAs, err = s.quarantineName(ctx, templateName); err == nil {
+			err = renameVM(ctx, template, result.QuarantinedAs)
+		}
+	default:
+		result.Action = "deleted"
+		err = destroyVM(ctx, template)
+	}
+	if err != nil && result.CleanupError != "" {
+		result.CleanupError += "; " + err.Error()
+	} else if err != nil {
+		result.CleanupError = err.Error()
+	}
+	return result
+}
+
+// quarantineName returns <name>-quarantined, or <name>-quarantined-<timestamp> when a template was already
+// quarantined under that name. The name is shortened to stay within the vSphere limit.
+func (s *Session) quarantineName(ctx context.Context, templateName string) (string, error) {
+	name := suffixedName(templateName, quarantineSuffix)
+	existing, err := s.findExisting(ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		name = suffixedName(templateName, fmt.Sprintf("%v-%d", quarantineSuffix, time.Now().Unix()))
+	}
+	return name, nil
+}
+
+// bootClone runs the smoke test on a clone of the template and destroys the clone afterwards
+func (s *Session) bootClone(ctx context.Context, template *object.VirtualMachine, result *SmokeTestResult) error {
+	pool := s.ResourcePool.Reference()
+	datastore := s.Datastore.Reference()
+	spec := types.VirtualMachineCloneSpec{
+		Location: types.VirtualMachineRelocateSpec{Pool: &pool, Datastore: &datastore},
+		PowerOn:  true,
+	}
+	if s.Host != nil {
+		host := s.Host.Reference()
+		spec.Location.Host = &host
+	}
+	task, err := template.Clone(ctx, s.Folder, result.VM, spec)
+	if err != nil {
+		return errors.Wrapf(err, "unable to clone template to %v", result.VM)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return errors.Wrapf(err, "failed waiting on clone task for %v", result.VM)
+	}
+	clone := object.NewVirtualMachine(s.Conn.Client, info.Result.(types.ManagedObjectReference))
+	defer func() {
+		if err := destroyVM(ctx, clone); err != nil {
+			result.CleanupError = err.Error()
+		}
+	}()
+
+	waitCtx, cancel := s.Options.smokeTestContext(ctx)
+	defer cancel()
+	if err := waitForGuest(waitCtx, clone, result); err != nil {
+		return err
+	}
+	if result.Port == 0 {
+		return nil
+	}
+	return probeTCP(waitCtx, net.JoinHostPort(result.IPAddress, strconv.Itoa(result.Port)))
+}
+
+// waitForGuest waits for VMware Tools to be running and the guest to report an IP address
+func waitForGuest(ctx context.Context, vm *object.VirtualMachine, result *SmokeTestResult) error {
+	pc := property.DefaultCollector(vm.Client())
+	err := property.Wait(ctx, pc, vm.Reference(), []string{"guest.toolsRunningStatus", "guest.ipAddress"}, func(changes []types.PropertyChange) bool {
+		for _, c := range changes {
+			value, _ := c.Val.(string)
+			switch c.Name {
+			case "guest.toolsRunningStatus":
+				result.ToolsStatus = value
+			case "guest.ipAddress":
+				result.IPAddress = value
+			}
+		}
+		return result.ToolsStatus == string(types.VirtualMachineToolsRunningStatusGuestToolsRunning) && result.IPAddress != ""
+	})
+	if err != nil {
+		return errors.Wrapf(err, "VMware Tools and an IP address did not appear (tools: %v, ip: %q)", result.ToolsStatus, result.IPAddress)
+	}
+	return nil
+}
+
+// probeTCP retries connecting to address until it succeeds or ctx is done
+func probeTCP(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(err, "unable to connect to %v", address)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// destroyVM powers off a VM, if needed, and deletes it
+func destroyVM(ctx context.Context, vm *object.VirtualMachine) error {
+	state, err := vm.PowerState(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to get power state of vm %s", vm.Reference())
+	}
+	if state == types.VirtualMachinePowerStatePoweredOn {
+		task, err := vm.PowerOff(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "unable to power off vm %s", vm.Reference())
+		}
+		if err := task.Wait(ctx); err != nil {
+			return errors.Wrapf(err, "failed waiting on power off task for %s", vm.Reference())
+		}
+	}
+	task, err := vm.Destroy(ctx)
+	if err != nil {
+		return errors.Wrapf(err, "unable to destroy vm %s", vm.Reference())
+	}
+	if err := task.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "failed waiting on destroy task for %s", vm.Reference())
+	}
+	return nil
+}
+
+// renameVM renames a VM or template
+func renameVM(ctx context.Context, vm *object.VirtualMachine, name string) error {
+	task, err := vm.Rename(ctx, name)
+	if err != nil {
+		return errors.Wrapf(err, "unable to rename vm %s to %v", vm.Reference(), name)
+	}
+	if err := task.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "failed waiting on rename task for %s", vm.Reference())
+	}
+	return nil
+}
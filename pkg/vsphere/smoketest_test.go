@@ -0,0 +1,269 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// bootSmokeTestClones makes the simulator report VMware Tools running and an IP for smoke test clones,
+// the simulator doesn't boot guests itself
+func bootSmokeTestClones(t *testing.T, ip string) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go func() {
+		for ctx.Err() == nil {
+			for _, e := range simulator.Map.All("VirtualMachine") {
+				if !strings.Contains(e.Entity().Name, "-smoketest-") {
+					continue
+				}
+				simulator.Map.Update(e, []types.PropertyChange{
+					{Name: "guest.toolsRunningStatus", Val: string(types.VirtualMachineToolsRunningStatusGuestToolsRunning)},
+					{Name: "guest.ipAddress", Val: ip},
+				})
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+}
+
+func TestDeployOVATemplateSmokeTest(t *testing.T) {
+	useTestTargets(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	bootSmokeTestClones(t, "127.0.0.1")
+	sim.conn.Options.SmokeTest = true
+	sim.conn.Options.SmokeTestTimeout = 10 * time.Second
+	sim.conn.Options.SmokeTestPort = listener.Addr().(*net.TCPAddr).Port
+	ovaPath := newTestOVA(t, testOVF{Name: "smoke-pass", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.SmokeTest == nil || !info.SmokeTest.Passed || info.SmokeTest.IPAddress != "127.0.0.1" {
+		t.Fatalf("expected the smoke test to pass, actual: %+v", info.SmokeTest)
+	}
+	if _, err := sim.conn.GetVM(info.SmokeTest.VM); err == nil {
+		t.Fatalf("expected the clone %v to be destroyed", info.SmokeTest.VM)
+	}
+}
+
+func TestDeployOVATemplateSmokeTestFailure(t *testing.T) {
+	tests := map[string]struct {
+		failure  string
+		action   string
+		template string
+	}{
+		"delete":     {SmokeTestFailureDelete, "deleted", ""},
+		"quarantine": {SmokeTestFailureQuarantine, "quarantined", "smoke-quarantine" + quarantineSuffix},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useTestTargets(t)
+			sim.conn.Options.SmokeTest = true
+			sim.conn.Options.SmokeTestTimeout = 100 * time.Millisecond
+			sim.conn.Options.SmokeTestFailure = tc.failure
+			ovaPath := newTestOVA(t, testOVF{Name: "smoke-" + name, OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+			info, err := sim.conn.DeployOVATemplate(ovaPath)
+			if err == nil {
+				t.Fatal("expected the smoke test to fail")
+			}
+			if info.SmokeTest == nil || info.SmokeTest.Passed || info.SmokeTest.Action != tc.action || info.SmokeTest.CleanupError != "" {
+				t.Fatalf("expected the template to be %v, actual: %+v", tc.action, info.SmokeTest)
+			}
+			if _, err := sim.conn.GetVM("smoke-" + name); err == nil {
+				t.Fatal("expected the template to be gone")
+			}
+			if info.SmokeTest.QuarantinedAs != tc.template {
+				t.Fatalf("expected: %q, actual: %q", tc.template, info.SmokeTest.QuarantinedAs)
+			}
+			if tc.template != "" {
+				if _, err := sim.conn.GetVM(tc.template); err != nil {
+					t.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestDeployOVATemplateSmokeTestQuarantineAgain(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.SmokeTest = true
+	sim.conn.Options.SmokeTestTimeout = 100 * time.Millisecond
+	sim.conn.Options.SmokeTestFailure = SmokeTestFailureQuarantine
+	sim.conn.Options.Name = strings.Repeat("q", maxNameLength)
+	ovaPath := newTestOVA(t, testOVF{Name: "smoke-again", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	var names []string
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			// the simulator doesn't make the directories of same-named VMs unique
+			createTestDatastore(t, "DC0_H0", "QuarantineDS")
+			var err error
+			if sim.conn.Datastore, err = sim.conn.GetDatastoreOrDefault("/DC0/datastore/QuarantineDS"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		info, err := sim.conn.DeployOVATemplate(ovaPath)
+		if err == nil {
+			t.Fatal("expected the smoke test to fail")
+		}
+		if info.SmokeTest == nil || info.SmokeTest.Action != "quarantined" || info.SmokeTest.CleanupError != "" {
+			t.Fatalf("expected the template to be quarantined, actual: %+v", info.SmokeTest)
+		}
+		name := info.SmokeTest.QuarantinedAs
+		if len(name) > maxNameLength || !strings.Contains(name, quarantineSuffix) {
+			t.Fatalf("expected a quarantined name within %d characters, actual: %v", maxNameLength, name)
+		}
+		if _, err := sim.conn.GetVM(name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if names[0] == names[1] {
+		t.Fatalf("expected a unique quarantined name, actual: %v", names)
+	}
+}
+
+func TestDeployOVATemplateSmokeTestQuarantineFamily(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.SmokeTest = true
+	sim.conn.Options.SmokeTestTimeout = 100 * time.Millisecond
+	sim.conn.Options.SmokeTestFailure = SmokeTestFailureQuarantine
+	sim.conn.Options.Family = "smoke-family"
+	ovaPath := newTestOVA(t, testOVF{Name: "smoke-family", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil {
+		t.Fatal("expected the smoke test to fail")
+	}
+	if info.SmokeTest == nil || info.SmokeTest.Action != "quarantined" {
+		t.Fatalf("expected the template to be quarantined, actual: %+v", info.SmokeTest)
+	}
+	vm, err := sim.conn.GetVM(info.SmokeTest.QuarantinedAs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := sim.conn.attachedTags(sim.conn.Ctx, []mo.VirtualMachine{{ManagedEntity: mo.ManagedEntity{ExtensibleManagedObject: mo.ExtensibleManagedObject{Self: vm.Reference()}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags[vm.Reference()]) != 0 {
+		t.Fatalf("expected the quarantined template to have no family tags, actual: %v", tags[vm.Reference()])
+	}
+}
+
+func TestDeployOVATemplateSmokeTestDeadline(t *testing.T) {
+	useTestTargets(t)
+	// the session's deadline leaves the smoke test a second once the cleanup time is kept
+	ctx, cancel := context.WithTimeout(context.Background(), smokeTestCleanupTime+time.Second)
+	defer cancel()
+	sessionCtx := sim.conn.Ctx
+	sim.conn.Ctx = ctx
+	t.Cleanup(func() { sim.conn.Ctx = sessionCtx })
+	sim.conn.Options.SmokeTest = true
+	ovaPath := newTestOVA(t, testOVF{Name: "smoke-deadline", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err == nil {
+		t.Fatal("expected the smoke test to fail")
+	}
+	if ctx.Err() != nil {
+		t.Fatalf("expected the smoke test to give up before the deadline of the session, %v", err)
+	}
+	if info.SmokeTest == nil || info.SmokeTest.Passed || info.SmokeTest.DurationSeconds > 10 || info.SmokeTest.Action != "deleted" || info.SmokeTest.CleanupError != "" {
+		t.Fatalf("expected the smoke test to time out and the template to be deleted, actual: %+v", info.SmokeTest)
+	}
+	if !strings.Contains(info.SmokeTest.Error, context.DeadlineExceeded.Error()) {
+		t.Fatalf("expected the smoke test to time out, actual: %v", info.SmokeTest.Error)
+	}
+	if _, err := sim.conn.GetVM("smoke-deadline"); err == nil {
+		t.Fatal("expected the template to be gone")
+	}
+}
+
+func TestProbeTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := probeTCP(ctx, address); err != nil {
+		t.Fatal(err)
+	}
+
+	listener.Close()
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := probeTCP(ctx, net.JoinHostPort("127.0.0.1", strconv.Itoa(listener.Addr().(*net.TCPAddr).Port))); err == nil {
+		t.Fatal("expected the probe of a closed port to fail")
+	}
+}
+
+func TestValidateSmokeTest(t *testing.T) {
+	tests := map[string]struct {
+		options DeployOptions
+		err     bool
+	}{
+		"disabled":           {DeployOptions{NICPolicy: NICPolicyRemove}, false},
+		"defaults":           {DeployOptions{SmokeTest: true}, false},
+		"quarantine":         {DeployOptions{SmokeTest: true, SmokeTestFailure: SmokeTestFailureQuarantine}, false},
+		"unknown failure":    {DeployOptions{SmokeTest: true, SmokeTestFailure: "keep"}, true},
+		"nic policy remove":  {DeployOptions{SmokeTest: true, NICPolicy: NICPolicyRemove}, true},
+		"nic policy replace": {DeployOptions{SmokeTest: true, NICPolicy: NICPolicyReplace}, false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			useTestTargets(t)
+			sim.conn.Options = tc.options
+			err := sim.conn.validateSmokeTest()
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %v, actual: %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestSmokeTestContext(t *testing.T) {
+	deadline := time.Now().Add(time.Hour)
+	parent, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	tests := map[string]struct {
+		ctx      context.Context
+		timeout  time.Duration
+		expected time.Time
+	}{
+		"timeout":                  {parent, time.Minute, time.Now().Add(time.Minute)},
+		"timeout beyond deadline":  {parent, 2 * time.Hour, deadline.Add(-smokeTestCleanupTime)},
+		"rest of the deadline":     {parent, 0, deadline.Add(-smokeTestCleanupTime)},
+		"no deadline":              {context.Background(), 0, time.Now().Add(defaultSmokeTestTimeout)},
+		"timeout without deadline": {context.Background(), time.Minute, time.Now().Add(time.Minute)},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := DeployOptions{SmokeTestTimeout: tc.timeout}.smokeTestContext(tc.ctx)
+			defer cancel()
+			actual, ok := ctx.Deadline()
+			if !ok || actual.Sub(tc.expected) > time.Second || tc.expected.Sub(actual) > time.Second {
+				t.Fatalf("expected a deadline of %v, actual: %v", tc.expected, actual)
+			}
+		})
+	}
+}
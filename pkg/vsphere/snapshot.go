@@ -0,0 +1,58 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ensureSnapshot returns the snapshot of a VM with a name, it is created when the VM doesn't have it yet
+func ensureSnapshot(ctx context.Context, vm *object.VirtualMachine, name string) (*types.ManagedObjectReference, error) {
+	ref, err := findSnapshot(ctx, vm, name)
+	if err != nil || ref != nil {
+		return ref, err
+	}
+	task, err := vm.CreateSnapshot(ctx, name, "", false, false)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create snapshot %v", name)
+	}
+	info, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed waiting on create snapshot task for %v", name)
+	}
+	snapshot, ok := info.Result.(types.ManagedObjectReference)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("create snapshot task for %v returned no snapshot", name))
+	}
+	return &snapshot, nil
+}
+
+// findSnapshot returns the first snapshot of a VM with a name, or nil if there is none
+func findSnapshot(ctx context.Context, vm *object.VirtualMachine, name string) (*types.ManagedObjectReference, error) {
+	var props mo.VirtualMachine
+	if err := vm.Properties(ctx, vm.Reference(), []string{"snapshot"}, &props); err != nil {
+		return nil, errors.Wrap(err, "unable to get virtual machine snapshots")
+	}
+	if props.Snapshot == nil {
+		return nil, nil
+	}
+	return findSnapshotInTree(props.Snapshot.RootSnapshotList, name), nil
+}
+
+func findSnapshotInTree(tree []types.VirtualMachineSnapshotTree, name string) *types.ManagedObjectReference {
+	for _, node := range tree {
+		if node.Name == name {
+			ref := node.Snapshot
+			return &ref
+		}
+		if ref := findSnapshotInTree(node.ChildSnapshotList, name); ref != nil {
+			return ref
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"testing"
+)
+
+func TestDeployOVATemplateSnapshot(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Snapshot = "base"
+	ovaPath := newTestOVA(t, testOVF{Name: "snapshot-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Snapshot == nil {
+		t.Fatal("expected a snapshot")
+	}
+	if info.Kind != KindTemplate {
+		t.Fatalf("expected: %v, actual: %v", KindTemplate, info.Kind)
+	}
+
+	again, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.AlreadyExists || again.Snapshot == nil || *again.Snapshot != *info.Snapshot {
+		t.Fatalf("expected: %v, actual: %v", info.Snapshot, again.Snapshot)
+	}
+}
+
+func TestEnsureSnapshotIdempotent(t *testing.T) {
+	vm, err := sim.conn.GetVM("DC0_H0_VM0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := ensureSnapshot(sim.conn.Ctx, vm, "idempotent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := ensureSnapshot(sim.conn.Ctx, vm, "idempotent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first == nil || second == nil || *first != *second {
+		t.Fatalf("expected the same snapshot twice, actual: %v and %v", first, second)
+	}
+}
@@ -0,0 +1,130 @@
+package vsphere
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// StoragePlacement describes the member of a datastore cluster an OVA is imported to
+type StoragePlacement struct {
+	DatastoreCluster string `json:"datastoreCluster"`
+	Datastore        string `json:"datastore"`
+	StorageDRS       bool   `json:"storageDRS"`
+	Reason           string `json:"reason"`
+}
+
+// PlaceOnDatastoreCluster picks the member of a datastore cluster to import an OVA to. With Storage DRS enabled
+// the top recommendation of StorageResourceManager.RecommendDatastores is used, otherwise the member with the most free space.
+func (s *Session) PlaceOnDatastoreCluster(pod *object.StoragePod, ovaPath string) (*object.Datastore, StoragePlacement, error) {
+	placement := StoragePlacement{DatastoreCluster: pod.Name()}
+	if s.ResourcePool == nil {
+		return nil, placement, errors.New("no resource pool specified in connection session")
+	}
+
+	var podMo mo.StoragePod
+	pc := s.Conn.PropertyCollector()
+	if err := pc.RetrieveOne(s.Ctx, pod.Reference(), []string{"childEntity", "podStorageDrsEntry"}, &podMo); err != nil {
+		return nil, placement, errors.Wrapf(err, "unable to retrieve details of datastore cluster %v", pod.Name())
+	}
+
+	candidates, err := s.datastoreCandidates(s.Ctx)
+	if err != nil {
+		return nil, placement, err
+	}
+	var members []datastoreCandidate
+	for _, ds := range candidates {
+		if containsRef(podMo.ChildEntity, ds.Reference()) {
+			members = append(members, ds)
+		}
+	}
+	if len(members) == 0 {
+		return nil, placement, errors.New(fmt.Sprintf("datastore cluster %v has no usable datastores", pod.Name()))
+	}
+	mostFree := members[0]
+	for _, ds := range members[1:] {
+		if ds.Summary.FreeSpace > mostFree.Summary.FreeSpace {
+			mostFree = ds
+		}
+	}
+
+	if podMo.PodStorageDrsEntry == nil || !podMo.PodStorageDrsEntry.StorageDrsConfig.PodConfig.Enabled {
+		placement.Datastore = mostFree.Name
+		placement.Reason = fmt.Sprintf("Storage DRS is disabled, member with the most free space (%.2fGB)", float64(mostFree.Summary.FreeSpace)/(1<<30))
+		return mostFree.object, placement, nil
+	}
+
+	// the recommendation needs the disks of the VM, they are taken from an import spec created against any member
+	importSpec, err := s.placementImportSpec(ovaPath, mostFree.object)
+	if err != nil {
+		return nil, placement, err
+	}
+
+	podRef := pod.Reference()
+	vmConfig := types.VmPodConfigForPlacement{StoragePod: podRef}
+	for _, change := range importSpec.ConfigSpec.DeviceChange {
+		if disk, ok := change.GetVirtualDeviceConfigSpec().Device.(*types.VirtualDisk); ok {
+			vmConfig.Disk = append(vmConfig.Disk, types.PodDiskLocator{DiskId: disk.Key})
+		}
+	}
+	poolRef := s.ResourcePool.Reference()
+	storageSpec := types.StoragePlacementSpec{
+		Type:         string(types.StoragePlacementSpecPlacementTypeCreate),
+		ResourcePool: &poolRef,
+		ConfigSpec:   &importSpec.ConfigSpec,
+		PodSelectionSpec: types.StorageDrsPodSelectionSpec{
+			StoragePod:      &podRef,
+			InitialVmConfig: []types.VmPodConfigForPlacement{vmConfig},
+		},
+	}
+	if s.Folder != nil {
+		folderRef := s.Folder.Reference()
+		storageSpec.Folder = &folderRef
+	}
+
+	srm := object.NewStorageResourceManager(s.Conn.Client)
+	result, err := srm.RecommendDatastores(s.Ctx, storageSpec)
+	if err != nil {
+		return nil, placement, errors.Wrapf(err, "unable to get Storage DRS recommendations for datastore cluster %v", pod.Name())
+	}
+	for _, rec := range result.Recommendations {
+		for _, action := range rec.Action {
+			pa, ok := action.(*types.StoragePlacementAction)
+			if !ok {
+				continue
+			}
+			for _, ds := range members {
+				if ds.Reference() == pa.Destination {
+					placement.Datastore = ds.Name
+					placement.StorageDRS = true
+					placement.Reason = rec.ReasonText
+					return ds.object, placement, nil
+				}
+			}
+		}
+	}
+	return nil, placement, errors.New(fmt.Sprintf("Storage DRS has no usable recommendation for datastore cluster %v", pod.Name()))
+}
+
+// placementImportSpec creates the import spec of an OVA on a datastore, it is used to ask DRS and
+// Storage DRS for recommendations before the actual import
+func (s *Session) placementImportSpec(ovaPath string, datastore *object.Datastore) (*types.VirtualMachineImportSpec, error) {
+	ovaClient, err := newOVA(s.Conn, ovaPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "unable to create ova client")
+	}
+	entityName := ovaBaseName(ovaPath)
+	spec, err := ovaClient.getImportSpec(s.Ctx, ovaPath, s.ResourcePool, datastore, s.importSpecParams(entityName))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "unable to create import spec for template (%s)", ovaPath)
+	}
+	importSpec, ok := spec.ImportSpec.(*types.VirtualMachineImportSpec)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unsupported import spec %T for placement", spec.ImportSpec))
+	}
+	return importSpec, nil
+}
@@ -0,0 +1,101 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"testing"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// createTestStoragePod creates a datastore cluster with a single new member datastore mounted on DC0_H0
+func createTestStoragePod(t *testing.T, name string, sdrs bool) *object.StoragePod {
+	t.Helper()
+	member := name + "_DS"
+	createTestDatastore(t, "DC0_H0", member)
+	ds, err := sim.conn.GetDatastoreOrDefault(member)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folders, err := sim.conn.Datacenter.Folders(sim.conn.Ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod, err := folders.DatastoreFolder.CreateStoragePod(sim.conn.Ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := pod.MoveInto(sim.conn.Ctx, []types.ManagedObjectReference{ds.Reference()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(sim.conn.Ctx); err != nil {
+		t.Fatal(err)
+	}
+	srm := object.NewStorageResourceManager(sim.conn.Conn.Client)
+	spec := types.StorageDrsConfigSpec{PodConfigSpec: &types.StorageDrsPodConfigSpec{Enabled: types.NewBool(sdrs)}}
+	task, err = srm.ConfigureStorageDrsForPod(sim.conn.Ctx, pod, spec, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := task.Wait(sim.conn.Ctx); err != nil {
+		t.Fatal(err)
+	}
+	pod, err = sim.conn.GetDatastoreClusterOrDefault(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pod
+}
+
+func TestPlaceOnDatastoreClusterSDRSDisabled(t *testing.T) {
+	useTestTargets(t)
+	pod := createTestStoragePod(t, "POD_MANUAL", false)
+	ovaPath := newTestOVA(t, testOVF{Name: "pod-manual", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	ds, placement, err := sim.conn.PlaceOnDatastoreCluster(pod, ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Name() != "POD_MANUAL_DS" || placement.Datastore != "POD_MANUAL_DS" {
+		t.Fatalf("expected: POD_MANUAL_DS, actual: %v", placement.Datastore)
+	}
+	if placement.StorageDRS {
+		t.Fatal("expected the fallback placement without Storage DRS")
+	}
+}
+
+func TestPlaceOnDatastoreClusterSDRS(t *testing.T) {
+	useTestTargets(t)
+	pod := createTestStoragePod(t, "POD_SDRS", true)
+	ovaPath := newTestOVA(t, testOVF{Name: "pod-sdrs", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	ds, placement, err := sim.conn.PlaceOnDatastoreCluster(pod, ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !placement.StorageDRS {
+		t.Fatalf("expected a Storage DRS recommendation, actual: %+v", placement)
+	}
+	if placement.Datastore != "POD_SDRS_DS" {
+		t.Fatalf("expected: POD_SDRS_DS, actual: %v", placement.Datastore)
+	}
+
+	sim.conn.Datastore = ds
+	if _, err := sim.conn.DeployOVATemplate(ovaPath); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetDatastoreClusterNotFound(t *testing.T) {
+	name := "/DC0/datastore/i_dont_exist"
+	errMsg := "error finding datastore cluster /DC0/datastore/i_dont_exist: datastore cluster '/DC0/datastore/i_dont_exist' not found"
+	_, err := sim.conn.GetDatastoreClusterOrDefault(name)
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if err.Error() != errMsg {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err.Error())
+	}
+}
@@ -0,0 +1,155 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/pbm"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// pbmClient returns a client of the storage policy (SPBM) API for the session
+func (s *Session) pbmClient(ctx context.Context) (*pbm.Client, error) {
+	if s.IsESXi() {
+		return nil, errors.New("storage policies need a vCenter, not a standalone ESXi host")
+	}
+	c, err := pbm.NewClient(ctx, s.Conn.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create storage policy client")
+	}
+	return c, nil
+}
+
+// storagePolicyID returns the ID of a storage policy by name
+func (s *Session) storagePolicyID(ctx context.Context, name string) (string, error) {
+	c, err := s.pbmClient(ctx)
+	if err != nil {
+		return "", err
+	}
+	id, err := c.ProfileIDByName(ctx, name)
+	if err != nil {
+		return "", errors.Wrapf(err, "error finding storage policy %s", name)
+	}
+	return id, nil
+}
+
+// Capability namespaces of storage policies. Encryption is a data service policy, with the ENCRYPTION line of service,
+// that a storage policy refers to by ID, or the vmwarevmcrypt I/O filter.
+const (
+	dataServiceNamespace = "com.vmware.storageprofile.dataservice"
+	encryptionNamespace  = "vmwarevmcrypt"
+)
+
+// storagePolicyEncrypts checks whether a storage policy, by ID, encrypts the VMs it's applied to
+func (s *Session) storagePolicyEncrypts(ctx context.Context, id string) (bool, error) {
+	c, err := s.pbmClient(ctx)
+	if err != nil {
+		return false, err
+	}
+	retrieve := func(ids []string) ([]pbmtypes.BasePbmProfile, error) {
+		var profileIDs []pbmtypes.PbmProfileId
+		for _, id := range ids {
+			profileIDs = append(profileIDs, pbmtypes.PbmProfileId{UniqueId: id})
+		}
+		profiles, err := c.RetrieveContent(ctx, profileIDs)
+		return profiles, errors.Wrap(err, "unable to retrieve storage policies")
+	}
+	profiles, err := retrieve([]string{id})
+	if err != nil {
+		return false, err
+	}
+	return encryptionCapability(profiles, retrieve)
+}
+
+// encryptionCapability checks whether any of profiles has an encryption capability, the data service policies they
+// refer to are looked up with retrieve
+func encryptionCapability(profiles []pbmtypes.BasePbmProfile, retrieve func([]string) ([]pbmtypes.BasePbmProfile, error)) (bool, error) {
+	var dataServices []string
+	for _, profile := range profiles {
+		capability, ok := profile.(*pbmtypes.PbmCapabilityProfile)
+		if !ok {
+			continue
+		}
+		if capability.LineOfService == string(pbmtypes.PbmLineOfServiceInfoLineOfServiceEnumENCRYPTION) {
+			return true, nil
+		}
+		constraints, ok := capability.Constraints.(*pbmtypes.PbmCapabilitySubProfileConstraints)
+		if !ok {
+			continue
+		}
+		for _, sub := range constraints.SubProfiles {
+			for _, c := range sub.Capability {
+				switch c.Id.Namespace {
+				case encryptionNamespace:
+					return true, nil
+				case dataServiceNamespace:
+					dataServices = append(dataServices, c.Id.Id)
+				}
+			}
+		}
+	}
+	if len(dataServices) == 0 || retrieve == nil {
+		return false, nil
+	}
+	referenced, err := retrieve(dataServices)
+	if err != nil {
+		return false, err
+	}
+	// data service policies don't refer to other policies
+	return encryptionCapability(referenced, nil)
+}
+
+// checkStoragePolicy fails when the session's datastore isn't compatible with the storage policy of the import,
+// by ID. It's run even when the preflight is skipped, an import with a policy the datastore can't satisfy fails.
+func (s *Session) checkStoragePolicy(ctx context.Context, id string) error {
+	if s.Datastore == nil {
+		return errors.New("no datastore specified in connection session")
+	}
+	c, err := s.pbmClient(ctx)
+	if err != nil {
+		return err
+	}
+	ref := s.Datastore.Reference()
+	hubs := []pbmtypes.PbmPlacementHub{{HubType: ref.Type, HubId: ref.Value}}
+	requirements := []pbmtypes.BasePbmPlacementRequirement{
+		&pbmtypes.PbmPlacementCapabilityProfileRequirement{ProfileId: pbmtypes.PbmProfileId{UniqueId: id}},
+	}
+	compatibility, err := c.CheckRequirements(ctx, hubs, nil, requirements)
+	if err != nil {
+		return errors.Wrapf(err, "unable to check datastore %v against storage policy %v", s.Datastore.Name(), s.Options.StoragePolicy)
+	}
+	if reasons := incompatibility(compatibility, ref); len(reasons) > 0 {
+		return errors.New(fmt.Sprintf("datastore %v is not compatible with storage policy %v: %v", s.Datastore.Name(), s.Options.StoragePolicy, strings.Join(reasons, ", ")))
+	}
+	return nil
+}
+
+// incompatibility returns the reasons a datastore isn't compatible with a storage policy, none when it is
+func incompatibility(compatibility pbm.PlacementCompatibilityResult, ds types.ManagedObjectReference) []string {
+	var reasons []string
+	for _, res := range compatibility {
+		if res.Hub.HubId != ds.Value {
+			continue
+		}
+		for _, fault := range res.Error {
+			reasons = append(reasons, fault.LocalizedMessage)
+		}
+	}
+	return reasons
+}
+
+// applyStoragePolicy sets the storage policy of the VM home and of every disk of an import spec
+func applyStoragePolicy(spec *types.VirtualMachineImportSpec, id string) {
+	profile := []types.BaseVirtualMachineProfileSpec{&types.VirtualMachineDefinedProfileSpec{ProfileId: id}}
+	spec.ConfigSpec.VmProfile = profile
+	for _, change := range spec.ConfigSpec.DeviceChange {
+		dc := change.GetVirtualDeviceConfigSpec()
+		if _, ok := dc.Device.(*types.VirtualDisk); ok {
+			dc.Profile = profile
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmware/govmomi/pbm"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+func TestDeployOVATemplateStoragePolicy(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.StoragePolicy = "vSAN Default Storage Policy"
+	ovaPath := newTestOVA(t, testOVF{Name: "policy-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := sim.conn.storagePolicyID(sim.conn.Ctx, "vSAN Default Storage Policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.StoragePolicyID == "" || info.StoragePolicyID != expected {
+		t.Fatalf("expected: %v, actual: %v", expected, info.StoragePolicyID)
+	}
+}
+
+func TestDeployOVATemplateStoragePolicyImportSpec(t *testing.T) {
+	useTestTargets(t)
+	recorder := &importSpecRecorder{RoundTripper: sim.conn.Conn.Client.RoundTripper}
+	sim.conn.Conn.Client.RoundTripper = recorder
+	t.Cleanup(func() { sim.conn.Conn.Client.RoundTripper = recorder.RoundTripper })
+	sim.conn.Options.StoragePolicy = "vSAN Default Storage Policy"
+	sim.conn.Options.SkipPreflight = true
+	ovaPath := newTestOVA(t, testOVF{Name: "policy-spec-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"})
+
+	info, err := sim.conn.DeployOVATemplate(ovaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recorder.specs) != 1 {
+		t.Fatalf("expected 1 import, actual: %v", len(recorder.specs))
+	}
+	spec, ok := recorder.specs[0].(*types.VirtualMachineImportSpec)
+	if !ok {
+		t.Fatalf("expected a VM import spec, actual: %T", recorder.specs[0])
+	}
+	if len(spec.ConfigSpec.VmProfile) != 1 || spec.ConfigSpec.VmProfile[0].(*types.VirtualMachineDefinedProfileSpec).ProfileId != info.StoragePolicyID {
+		t.Fatalf("expected the VM home to get policy %v, actual: %v", info.StoragePolicyID, spec.ConfigSpec.VmProfile)
+	}
+	disks := 0
+	for _, change := range spec.ConfigSpec.DeviceChange {
+		dev := change.GetVirtualDeviceConfigSpec()
+		if _, ok := dev.Device.(*types.VirtualDisk); !ok {
+			continue
+		}
+		disks++
+		if len(dev.Profile) != 1 || dev.Profile[0].(*types.VirtualMachineDefinedProfileSpec).ProfileId != info.StoragePolicyID {
+			t.Fatalf("expected the disk to get policy %v, actual: %v", info.StoragePolicyID, dev.Profile)
+		}
+	}
+	if disks == 0 {
+		t.Fatal("expected the import spec to have a disk")
+	}
+}
+
+func TestCheckStoragePolicy(t *testing.T) {
+	useTestTargets(t)
+	id, err := sim.conn.storagePolicyID(sim.conn.Ctx, "vSAN Default Storage Policy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.conn.checkStoragePolicy(sim.conn.Ctx, id); err != nil {
+		t.Fatalf("expected the datastore to be compatible, actual: %v", err)
+	}
+
+	ds := sim.conn.Datastore
+	sim.conn.Datastore = nil
+	t.Cleanup(func() { sim.conn.Datastore = ds })
+	if err := sim.conn.checkStoragePolicy(sim.conn.Ctx, id); err == nil {
+		t.Fatal("expected an error without a datastore")
+	}
+}
+
+func TestIncompatibility(t *testing.T) {
+	ds := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-1"}
+	fault := func(msg string) types.LocalizedMethodFault {
+		return types.LocalizedMethodFault{LocalizedMessage: msg}
+	}
+	tests := map[string]struct {
+		compatibility pbm.PlacementCompatibilityResult
+		expected      []string
+	}{
+		"compatible": {
+			compatibility: pbm.PlacementCompatibilityResult{{Hub: pbmtypes.PbmPlacementHub{HubType: "Datastore", HubId: "datastore-1"}}},
+		},
+		"incompatible": {
+			compatibility: pbm.PlacementCompatibilityResult{{Hub: pbmtypes.PbmPlacementHub{HubType: "Datastore", HubId: "datastore-1"}, Error: []types.LocalizedMethodFault{fault("no encryption"), fault("no vsan")}}},
+			expected:      []string{"no encryption", "no vsan"},
+		},
+		"another datastore": {
+			compatibility: pbm.PlacementCompatibilityResult{{Hub: pbmtypes.PbmPlacementHub{HubType: "Datastore", HubId: "datastore-2"}, Error: []types.LocalizedMethodFault{fault("no vsan")}}},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := incompatibility(tc.compatibility, ds); !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected: %v, actual: %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestStoragePolicyNotFound(t *testing.T) {
+	errMsg := `error finding storage policy i_dont_exist: no pbm profile found with name: "i_dont_exist"`
+	_, err := sim.conn.storagePolicyID(sim.conn.Ctx, "i_dont_exist")
+	if err == nil {
+		t.Fatal("received an unexpected nil error")
+	}
+	if err.Error() != errMsg {
+		t.Fatalf("expected: %v, actual: %v", errMsg, err.Error())
+	}
+}
+
+func TestApplyStoragePolicy(t *testing.T) {
+	spec := &types.VirtualMachineImportSpec{}
+	spec.ConfigSpec.DeviceChange = []types.BaseVirtualDeviceConfigSpec{
+		&types.VirtualDeviceConfigSpec{Device: &types.VirtualDisk{}},
+		&types.VirtualDeviceConfigSpec{Device: &types.VirtualVmxnet3{}},
+	}
+	applyStoragePolicy(spec, "policy-id")
+
+	if len(spec.ConfigSpec.VmProfile) != 1 {
+		t.Fatalf("expected the VM home to get the policy, actual: %v", spec.ConfigSpec.VmProfile)
+	}
+	disk := spec.ConfigSpec.DeviceChange[0].GetVirtualDeviceConfigSpec()
+	if len(disk.Profile) != 1 || disk.Profile[0].(*types.VirtualMachineDefinedProfileSpec).ProfileId != "policy-id" {
+		t.Fatalf("expected the disk to get the policy, actual: %v", disk.Profile)
+	}
+	if nic := spec.ConfigSpec.DeviceChange[1].GetVirtualDeviceConfigSpec(); len(nic.Profile) != 0 {
+		t.Fatalf("expected the NIC to have no policy, actual: %v", nic.Profile)
+	}
+}
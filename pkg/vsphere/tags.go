@@ -0,0 +1,136 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// tagManager returns a vAPI tag manager for the session
+func (s *Session) tagManager() (*tags.Manager, error) {
+	c, err := s.RestClient()
+	if err != nil {
+		return nil, err
+	}
+	return tags.NewManager(c), nil
+}
+
+// findTag returns the tag referenced as category/tag
+func (s *Session) findTag(categoryAndTag string) (*tags.Tag, error) {
+	parts := strings.SplitN(categoryAndTag, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, errors.New(fmt.Sprintf("tag %q is not of the form category/tag", categoryAndTag))
+	}
+	m, err := s.tagManager()
+	if err != nil {
+		return nil, err
+	}
+	tag, err := m.GetTagForCategory(s.Ctx, parts[1], parts[0])
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding tag %s", categoryAndTag)
+	}
+	return tag, nil
+}
+
+// taggedObjects returns the references of all objects the category/tag is attached to
+func (s *Session) taggedObjects(categoryAndTag string) (map[types.ManagedObjectReference]bool, error) {
+	tag, err := s.findTag(categoryAndTag)
+	if err != nil {
+		return nil, err
+	}
+	m, err := s.tagManager()
+	if err != nil {
+		return nil, err
+	}
+	attached, err := m.ListAttachedObjects(s.Ctx, tag.ID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list objects tagged with %s", categoryAndTag)
+	}
+	refs := make(map[types.ManagedObjectReference]bool, len(attached))
+	for _, obj := range attached {
+		refs[obj.Reference()] = true
+	}
+	return refs, nil
+}
+
+// ensureTag returns the ID of a tag, the tag and its category are created when they don't exist.
+// New categories have a single tag per virtual machine.
+func (s *Session) ensureTag(ctx context.Context, category string, name string) (string, error) {
+	m, err := s.tagManager()
+	if err != nil {
+		return "", err
+	}
+	categories, err := m.GetCategories(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to list tag categories")
+	}
+	var categoryID string
+	for _, c := range categories {
+		if c.Name == category {
+			categoryID = c.ID
+		}
+	}
+	if categoryID == "" {
+		categoryID, err = m.CreateCategory(ctx, &tags.Category{
+			Name:            category,
+			Description:     "created by ovaimporter",
+			Cardinality:     "SINGLE",
+			AssociableTypes: []string{"VirtualMachine"},
+		})
+		if err != nil {
+			return "", errors.Wrapf(err, "unable to create tag category %v", category)
+		}
+	}
+	existing, err := m.GetTagsForCategory(ctx, categoryID)
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to list tags of category %v", category)
+	}
+	for _, t := range existing {
+		if t.Name == name {
+			return t.ID, nil
+		}
+	}
+	id, err := m.CreateTag(ctx, &tags.Tag{Name: name, CategoryID: categoryID})
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to create tag %v/%v", category, name)
+	}
+	return id, nil
+}
+
+// attachTag attaches category/name to a VM, the tag and category are created when they don't exist
+func (s *Session) attachTag(ctx context.Context, vm *object.VirtualMachine, category string, name string) error {
+	id, err := s.ensureTag(ctx, category, name)
+	if err != nil {
+		return err
+	}
+	m, err := s.tagManager()
+	if err != nil {
+		return err
+	}
+	if err := m.AttachTag(ctx, id, vm.Reference()); err != nil {
+		return errors.Wrapf(err, "unable to tag %v with %v/%v", vm.Reference(), category, name)
+	}
+	return nil
+}
+
+// detachTag detaches a category/tag from a VM
+func (s *Session) detachTag(ctx context.Context, vm *object.VirtualMachine, categoryAndTag string) error {
+	tag, err := s.findTag(categoryAndTag)
+	if err != nil {
+		return err
+	}
+	m, err := s.tagManager()
+	if err != nil {
+		return err
+	}
+	if err := m.DetachTag(ctx, tag.ID, vm.Reference()); err != nil {
+		return errors.Wrapf(err, "unable to detach %v from %v", categoryAndTag, vm.Reference())
+	}
+	return nil
+}
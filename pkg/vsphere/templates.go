@@ -0,0 +1,255 @@
+package vsphere
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// TemplateInfo describes a template and the metadata ovaimporter recorded on it
+type TemplateInfo struct {
+	Name       string            `json:"name"`
+	ID         string            `json:"id"`
+	Path       string            `json:"path"`
+	Kind       string            `json:"kind"`
+	GuestID    string            `json:"guestId,omitempty"`
+	NumCPUs    int32             `json:"numCPUs,omitempty"`
+	MemoryMB   int32             `json:"memoryMB,omitempty"`
+	Annotation string            `json:"annotation,omitempty"`
+	Metadata   map[string]string `json:"metadata"`
+	// Tags attached to the template as category/tag
+	Tags []string `json:"tags"`
+	// LinkedClones and NICs are only filled in by DescribeTemplate
+	LinkedClones []string `json:"linkedClones,omitempty"`
+	NICs         []NIC    `json:"nics,omitempty"`
+}
+
+// TemplateFilter selects the templates returned by ListTemplates
+type TemplateFilter struct {
+	// Folder the templates are in, or in a subfolder of, defaults to the datacenter's VM folder
+	Folder *object.Folder
+	// Tag is a category/tag the templates must have
+	Tag string
+	// Annotation is text the annotation of the templates must contain. A metadata line, e.g. ovaimporter.family=ubuntu,
+	// must match the value of the key exactly.
+	Annotation string
+}
+
+var templateProperties = []string{"name", "config.template", "config.annotation", "config.guestId", "config.hardware.numCPU", "config.hardware.memoryMB", "config.hardware.device"}
+
+// ListTemplates returns the templates that match a filter, sorted by path
+func (s *Session) ListTemplates(filter TemplateFilter) ([]TemplateInfo, error) {
+	ctx := s.Ctx
+	templates := []TemplateInfo{}
+	finder := find.NewFinder(s.Conn.Client, true)
+	finder.SetDatacenter(s.Datacenter)
+	// relative paths are relative to the VM folder of the datacenter
+	root := "."
+	if filter.Folder != nil {
+		root = filter.Folder.InventoryPath
+	}
+	vms, err := finder.VirtualMachineList(ctx, root+"/...")
+	if _, ok := err.(*find.NotFoundError); ok {
+		return templates, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error finding vms in %s", root)
+	}
+
+	var tagged map[types.ManagedObjectReference]bool
+	if filter.Tag != "" {
+		if tagged, err = s.taggedObjects(filter.Tag); err != nil {
+			return nil, err
+		}
+	}
+	paths := make(map[types.ManagedObjectReference]string, len(vms))
+	var refs []types.ManagedObjectReference
+	for _, vm := range vms {
+		if tagged != nil && !tagged[vm.Reference()] {
+			continue
+		}
+		paths[vm.Reference()] = vm.InventoryPath
+		refs = append(refs, vm.Reference())
+	}
+	if len(refs) == 0 {
+		return templates, nil
+	}
+	var props []mo.VirtualMachine
+	if err := property.DefaultCollector(s.Conn.Client).Retrieve(ctx, refs, templateProperties, &props); err != nil {
+		return nil, errors.Wrapf(err, "unable to get the properties of the vms in %s", root)
+	}
+	var matched []mo.VirtualMachine
+	for _, vm := range props {
+		if vm.Config == nil || !vm.Config.Template || !matchAnnotation(vm.Config.Annotation, filter.Annotation) {
+			continue
+		}
+		matched = append(matched, vm)
+	}
+	tags, err := s.attachedTags(ctx, matched)
+	if err != nil {
+		return nil, err
+	}
+	for _, vm := range matched {
+		info := templateInfo(vm, paths[vm.Self])
+		info.Tags = append(info.Tags, tags[vm.Self]...)
+		templates = append(templates, info)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Path < templates[j].Path })
+	return templates, nil
+}
+
+// matchAnnotation reports whether an annotation has the metadata key=value of filter, or contains filter when it isn't
+// a metadata line
+func matchAnnotation(annotation string, filter string) bool {
+	key, value, ok := parseMetadataLine(filter)
+	if !ok {
+		return strings.Contains(annotation, filter)
+	}
+	actual, ok := getMetadata(annotation)[key]
+	return ok && actual == value
+}
+
+// DescribeTemplate returns a template with its tags, linked clones and NICs
+func (s *Session) DescribeTemplate(name string) (TemplateInfo, error) {
+	vm, props, err := s.getTemplate(name)
+	if err != nil {
+		return TemplateInfo{}, err
+	}
+	info := templateInfo(*props, vm.InventoryPath)
+	tags, err := s.attachedTags(s.Ctx, []mo.VirtualMachine{*props})
+	if err != nil {
+		return info, err
+	}
+	info.Tags = append(info.Tags, tags[props.Self]...)
+	clones, err := s.linkedClones(s.Ctx, []mo.VirtualMachine{*props})
+	if err != nil {
+		return info, err
+	}
+	info.LinkedClones = clones[props.Self]
+	info.NICs, err = vmNICs(s.Ctx, vm)
+	return info, err
+}
+
+// DeleteTemplate deletes a template, it refuses to delete a template with linked clones unless force is set
+func (s *Session) DeleteTemplate(name string, force bool) (TemplateInfo, error) {
+	info, err := s.DescribeTemplate(name)
+	if err != nil {
+		return info, err
+	}
+	if len(info.LinkedClones) > 0 && !force {
+		return info, errors.New(fmt.Sprintf("%v has linked clones (%v), it can only be deleted with force", name, strings.Join(info.LinkedClones, ", ")))
+	}
+	vm := object.NewVirtualMachine(s.Conn.Client, types.ManagedObjectReference{Type: "VirtualMachine", Value: info.ID})
+	return info, destroyVM(s.Ctx, vm)
+}
+
+// MoveTemplate moves a template into a folder
+func (s *Session) MoveTemplate(name string, folder *object.Folder) (TemplateInfo, error) {
+	vm, _, err := s.getTemplate(name)
+	if err != nil {
+		return TemplateInfo{}, err
+	}
+	if err := moveVM(s.Ctx, vm, folder); err != nil {
+		return TemplateInfo{}, err
+	}
+	return s.DescribeTemplate(vm.InventoryPath)
+}
+
+// RenameTemplate renames a template, the new name is sanitized to the vSphere rules
+func (s *Session) RenameTemplate(name string, newName string) (TemplateInfo, error) {
+	vm, _, err := s.getTemplate(name)
+	if err != nil {
+		return TemplateInfo{}, err
+	}
+	newName, err = sanitizeName(newName)
+	if err != nil {
+		return TemplateInfo{}, err
+	}
+	if err := renameVM(s.Ctx, vm, newName); err != nil {
+		return TemplateInfo{}, err
+	}
+	return s.DescribeTemplate(path.Join(path.Dir(vm.InventoryPath), newName))
+}
+
+// getTemplate finds a template by name or inventory path
+func (s *Session) getTemplate(name string) (*object.VirtualMachine, *mo.VirtualMachine, error) {
+	vm, err := s.GetVM(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	var props mo.VirtualMachine
+	if err := vm.Properties(s.Ctx, vm.Reference(), templateProperties, &props); err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to get the properties of %v", name)
+	}
+	if props.Config == nil || !props.Config.Template {
+		return nil, nil, errors.New(fmt.Sprintf("%v is not a template", name))
+	}
+	return vm, &props, nil
+}
+
+// attachedTags returns the category/tag names attached to VMs, it returns no tags on a standalone ESXi host
+func (s *Session) attachedTags(ctx context.Context, vms []mo.VirtualMachine) (map[types.ManagedObjectReference][]string, error) {
+	names := make(map[types.ManagedObjectReference][]string)
+	if len(vms) == 0 || s.IsESXi() {
+		return names, nil
+	}
+	m, err := s.tagManager()
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]mo.Reference, 0, len(vms))
+	for _, vm := range vms {
+		refs = append(refs, vm.Self)
+	}
+	attached, err := m.GetAttachedTagsOnObjects(ctx, refs)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list the tags of the templates")
+	}
+	categories, err := m.GetCategories(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list tag categories")
+	}
+	categoryNames := make(map[string]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+	for _, a := range attached {
+		for _, tag := range a.Tags {
+			names[a.ObjectID.Reference()] = append(names[a.ObjectID.Reference()], categoryNames[tag.CategoryID]+"/"+tag.Name)
+		}
+		sort.Strings(names[a.ObjectID.Reference()])
+	}
+	return names, nil
+}
+
+func templateInfo(vm mo.VirtualMachine, inventoryPath string) TemplateInfo {
+	info := TemplateInfo{
+		Name:     vm.Name,
+		ID:       vm.Self.Value,
+		Path:     inventoryPath,
+		Kind:     KindVM,
+		Metadata: map[string]string{},
+		Tags:     []string{},
+	}
+	if vm.Config != nil {
+		if vm.Config.Template {
+			info.Kind = KindTemplate
+		}
+		info.GuestID = vm.Config.GuestId
+		info.NumCPUs = vm.Config.Hardware.NumCPU
+		info.MemoryMB = vm.Config.Hardware.MemoryMB
+		info.Annotation = vm.Config.Annotation
+		info.Metadata = getMetadata(vm.Config.Annotation)
+	}
+	return info
+}
@@ -0,0 +1,123 @@
+// +build !integration
+
+package vsphere
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTemplateManagement(t *testing.T) {
+	useTestTargets(t)
+	sim.conn.Options.Family = "tpl-family"
+	sim.conn.Options.Version = "1.0.0"
+	imported, err := sim.conn.DeployOVATemplate(newTestOVA(t, testOVF{Name: "tpl-tiny", OSType: "otherLinux64Guest", HardwareVersion: "vmx-13"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	templates, err := sim.conn.ListTemplates(TemplateFilter{Tag: FamilyTagCategory + "/tpl-family", Annotation: "ovaimporter.version=1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 1 || templates[0].ID != imported.VMObject.Reference().Value || templates[0].Path != "/DC0/vm/tpl-tiny" {
+		t.Fatalf("expected tpl-tiny, actual: %+v", templates)
+	}
+	if templates[0].Metadata[MetadataSourceDigest] != imported.Source.Digest {
+		t.Fatalf("expected the source digest in the metadata, actual: %v", templates[0].Metadata)
+	}
+	templates, err = sim.conn.ListTemplates(TemplateFilter{Annotation: "ovaimporter.family=no-such-family"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 0 {
+		t.Fatalf("expected no templates, actual: %+v", templates)
+	}
+
+	info, err := sim.conn.DescribeTemplate("tpl-tiny")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Tags) != 2 || info.Tags[0] != FamilyTagCategory+"/tpl-family" || info.Tags[1] != VersionTagCategory+"/1.0.0" {
+		t.Fatalf("unexpected tags %v", info.Tags)
+	}
+	if len(info.NICs) != 1 || len(info.LinkedClones) != 0 {
+		t.Fatalf("unexpected template %+v", info)
+	}
+
+	if _, err := sim.conn.Folder.CreateFolder(context.Background(), "tpl-folder"); err != nil {
+		t.Fatal(err)
+	}
+	folder, err := sim.conn.GetFolderOrDefault("/DC0/vm/tpl-folder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err = sim.conn.MoveTemplate("tpl-tiny", folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Path != "/DC0/vm/tpl-folder/tpl-tiny" {
+		t.Fatalf("expected: /DC0/vm/tpl-folder/tpl-tiny, actual: %v", info.Path)
+	}
+	templates, err = sim.conn.ListTemplates(TemplateFilter{Folder: folder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(templates) != 1 || templates[0].Name != "tpl-tiny" {
+		t.Fatalf("expected tpl-tiny in tpl-folder, actual: %+v", templates)
+	}
+
+	info, err = sim.conn.RenameTemplate("tpl-tiny", "tpl/renamed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Name != "tpl-renamed" || info.Path != "/DC0/vm/tpl-folder/tpl-renamed" {
+		t.Fatalf("unexpected renamed template %+v", info)
+	}
+
+	makeLinkedClone(t, imported.VMObject, "tpl-clone")
+	info, err = sim.conn.DeleteTemplate("tpl-renamed", false)
+	if err == nil {
+		t.Fatal("expected a template with linked clones not to be deleted")
+	}
+	if len(info.LinkedClones) != 1 || info.LinkedClones[0] != "tpl-clone" {
+		t.Fatalf("expected: [tpl-clone], actual: %v", info.LinkedClones)
+	}
+	if _, err := sim.conn.DeleteTemplate("tpl-renamed", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.conn.GetVM("tpl-renamed"); err == nil {
+		t.Fatal("expected tpl-renamed to be deleted")
+	}
+}
+
+func TestDescribeTemplateNotATemplate(t *testing.T) {
+	if _, err := sim.conn.DescribeTemplate("DC0_H0_VM0"); err == nil {
+		t.Fatal("expected an error for a VM")
+	}
+}
+
+func TestMatchAnnotation(t *testing.T) {
+	annotation := setMetadata("built by packer", map[string]string{"family": "ubuntu", "version": "1.17.3"})
+	tests := map[string]struct {
+		filter   string
+		expected bool
+	}{
+		"empty":            {"", true},
+		"text":             {"packer", true},
+		"missing text":     {"terraform", false},
+		"metadata":         {"ovaimporter.version=1.17.3", true},
+		"metadata prefix":  {"ovaimporter.version=1.17", false},
+		"metadata suffix":  {"ovaimporter.family=ubu", false},
+		"other value":      {"ovaimporter.family=centos", false},
+		"missing key":      {"ovaimporter.eulaSha256=", false},
+		"key without line": {"ovaimporter.version", true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if actual := matchAnnotation(annotation, tc.filter); actual != tc.expected {
+				t.Fatalf("expected: %v, actual: %v", tc.expected, actual)
+			}
+		})
+	}
+}